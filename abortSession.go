@@ -0,0 +1,14 @@
+package main
+
+// AbortSession closes the current session without saving.
+func (app *App) AbortSession(inv *Invocation) error {
+	return app.client.AbortSession(app.ctx)
+}
+
+func init() {
+	registerCommand(&Command{
+		Name:    "abort",
+		Summary: "Close the current session without saving.",
+		Run:     (*App).AbortSession,
+	})
+}
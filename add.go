@@ -2,56 +2,26 @@ package main
 
 import (
 	"fmt"
-	"os"
-	"time"
-
-	"github.com/pkg/errors"
-	"github.com/scgolang/nsm"
-	"github.com/scgolang/osc"
+	"io"
 )
 
 // Add tells gonzo to add a client.
-func (app *App) Add(args []string) error {
-	if len(args) < 2 {
-		return errors.New("add takes exactly two arguments")
-	}
-	var (
-		name       = args[0]
-		executable = args[1]
-	)
-	if err := app.Send(osc.Message{
-		Address: nsm.AddressServerAdd,
-		Arguments: osc.Arguments{
-			osc.String(name),
-			osc.String(executable),
-		},
-	}); err != nil {
-		return errors.Wrap(err, "sending add message")
-	}
-
-	select {
-	case <-time.After(2 * time.Second):
-		return errors.New("timeout")
-	case err := <-app.errors:
-		return err
-	case reply := <-app.replies:
-		app.debugf("got reply %s", reply)
-	}
-	return nil
+func (app *App) Add(inv *Invocation) error {
+	return app.client.Add(app.ctx, inv.Args[0], inv.Args[1])
 }
 
 func init() {
-	commandUsage["add"] = func() error {
-		fmt.Fprintf(os.Stderr, "Add a new client to the current session.\n")
-		fmt.Fprintf(os.Stderr, "\n")
-		fmt.Fprintf(os.Stderr, "Usage:\n")
-		fmt.Fprintf(os.Stderr, "gonzoctl add NAME PROGRAM\n")
-		fmt.Fprintf(os.Stderr, "\n")
-		fmt.Fprintf(os.Stderr, "NAME      is the name of the new client.\n")
-		fmt.Fprintf(os.Stderr, "PROGRAM   is the path to the executable for the client.\n")
-		fmt.Fprintf(os.Stderr, "\n")
-		fmt.Fprintf(os.Stderr, "Example:\n")
-		fmt.Fprintf(os.Stderr, "gonzoctl add sc-servers1 sc-servers\n")
-		return nil
-	}
+	registerCommand(&Command{
+		Name:    "add",
+		Summary: "Add a new client to the current session.",
+		Args: []Arg{
+			{Name: "NAME", Help: "is the name of the new client."},
+			{Name: "PROGRAM", Help: "is the path to the executable for the client."},
+		},
+		Help: func(w io.Writer) {
+			fmt.Fprintf(w, "Example:\n")
+			fmt.Fprintf(w, "gonzoctl add sc-servers1 sc-servers\n")
+		},
+		Run: (*App).Add,
+	})
 }
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// logColors are the ANSI colors used for client names by logs --all.
+var logColors = []string{"36", "33", "32", "35", "34", "31", "96", "93", "92", "95", "94", "91"}
+
+// LogLine is a line from the logs of a client.
+type LogLine struct {
+	Client string `json:"client"`
+	Stream string `json:"stream"`
+	Line   string `json:"line"`
+
+	color string
+	time  time.Time
+}
+
+// AllLogs is the result of logs --all.
+type AllLogs struct {
+	Lines []LogLine `json:"lines"`
+
+	width int
+}
+
+// Rows returns a row for each log line.
+func (l AllLogs) Rows() ([]string, [][]string) {
+	rows := make([][]string, len(l.Lines))
+	for i, line := range l.Lines {
+		rows[i] = []string{line.Client, line.Stream, line.Line}
+	}
+	return []string{"CLIENT", "STREAM", "LINE"}, rows
+}
+
+// WriteText writes each log line prefixed with the client name and stream.
+func (l AllLogs) WriteText(w io.Writer) error {
+	color := useColor()
+
+	for _, line := range l.Lines {
+		prefix := fmt.Sprintf("%-*s %-6s |", l.width, line.Client, line.Stream)
+		if color {
+			prefix = "\x1b[" + line.color + "m" + prefix + "\x1b[0m"
+		}
+		if _, err := fmt.Fprintln(w, prefix+" "+line.Line); err != nil {
+			return errors.Wrap(err, "printing log line")
+		}
+	}
+	return nil
+}
+
+// allLogs prints the logs of every client in the current session as one stream.
+// Lines that start with a timestamp are printed in the order of their timestamps.
+func (app *App) allLogs(streams []string, follow bool, backlog logBacklog) error {
+	poller := newLogPoller(app, backlog)
+
+	pollAll := func() error {
+		clients, err := app.clients()
+		if err != nil {
+			return err
+		}
+		logs := AllLogs{Lines: []LogLine{}}
+
+		for i, client := range clients {
+			if len(client.Name) > logs.width {
+				logs.width = len(client.Name)
+			}
+			for _, stream := range streams {
+				lines, err := poller.poll(logSource{client: client.Name, stream: stream})
+				if err != nil {
+					if !follow {
+						log.Printf("could not get %s of %s: %s", stream, client.Name, err)
+					}
+					app.debugf("could not get %s of %s: %s", stream, client.Name, err)
+					continue
+				}
+				var lastTime time.Time
+
+				for _, line := range lines {
+					if t, ok := lineTime(line); ok {
+						lastTime = t
+					}
+					logs.Lines = append(logs.Lines, LogLine{
+						Client: client.Name,
+						Stream: stream,
+						Line:   line,
+						color:  logColors[i%len(logColors)],
+						time:   lastTime,
+					})
+				}
+			}
+		}
+		sort.SliceStable(logs.Lines, func(i, j int) bool {
+			return logs.Lines[i].time.Before(logs.Lines[j].time)
+		})
+		if follow && len(logs.Lines) == 0 {
+			return nil
+		}
+		return errors.Wrap(app.print(logs), "printing client logs")
+	}
+	if follow {
+		return app.follow(pollAll)
+	}
+	return pollAll()
+}
+
+// useColor returns true if stdout is a terminal and the user has not disabled colors.
+func useColor() bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok || strings.TrimSpace(os.Getenv("TERM")) == "dumb" {
+		return false
+	}
+	fi, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
@@ -3,14 +3,13 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
-	"net"
 	"os"
-	"strconv"
+	"time"
 
 	"github.com/pkg/errors"
-	"github.com/scgolang/nsm"
-	"github.com/scgolang/osc"
+	"github.com/scgolang/gonzoctl/gonzo"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -20,18 +19,14 @@ var ErrDone = errors.New("done")
 // App holds the state for the application.
 type App struct {
 	Config
-	osc.Conn
+
+	client *gonzo.Client
 
 	cancel context.CancelFunc
 	ctx    context.Context
 	group  *errgroup.Group
-
-	errors  chan Error
-	replies chan osc.Message
 }
 
-type cmdFunc func(args []string) error
-
 // NewApp creates a new application.
 func NewApp(ctx context.Context, config Config) (*App, error) {
 	cctx, cancel := context.WithCancel(ctx)
@@ -43,43 +38,16 @@ func NewApp(ctx context.Context, config Config) (*App, error) {
 		cancel: cancel,
 		ctx:    gctx,
 		group:  g,
-
-		errors:  make(chan Error),
-		replies: make(chan osc.Message),
-	}
-	if err := app.initialize(); err != nil {
-		return nil, errors.Wrap(err, "could not initialize app")
 	}
 	return app, nil
 }
 
 // Close closes the app.
 func (app *App) Close() error {
-	close(app.errors)
-	close(app.replies)
-	return app.Conn.Close()
-}
-
-// Error handles error replies from gonzo.
-func (app *App) Error(msg osc.Message) error {
-	if len(msg.Arguments) != 3 {
-		return errors.New("expected 3 arguments for error message")
-	}
-	address, err := msg.Arguments[0].ReadString()
-	if err != nil {
-		return errors.Wrap(err, "reading address in error message")
-	}
-	code, err := msg.Arguments[1].ReadInt32()
-	if err != nil {
-		return errors.Wrap(err, "reading code in error message")
-	}
-	errmsg, err := msg.Arguments[2].ReadString()
-	if err != nil {
-		return errors.Wrap(err, "reading errmsg in error message")
+	if app.client == nil {
+		return nil
 	}
-	app.errors <- NewError(nsm.NewError(nsm.Code(code), errmsg), address)
-	app.debugf("received error: address=%s code=%d message=%s", address, code, errmsg)
-	return nil
+	return app.client.Close()
 }
 
 // Go runs a new goroutine as part of an errgroup.Group
@@ -87,45 +55,47 @@ func (app *App) Go(f func() error) {
 	app.group.Go(f)
 }
 
-// Ping sends a ping message.
-func (app *App) Ping(args []string) error {
-	return errors.Wrap(app.Send(osc.Message{Address: "/ping"}), "sending ping")
+// PingResult is the result of the ping command.
+type PingResult struct {
+	Server string        `json:"server"`
+	RTT    time.Duration `json:"rtt"`
 }
 
-// Pong handles ping responses from gonzo.
-func (app *App) Pong(msg osc.Message) error {
-	fmt.Println("pong")
-	return nil
+// Rows returns the server address and round trip time.
+func (p PingResult) Rows() ([]string, [][]string) {
+	return []string{"SERVER", "RTT"}, [][]string{{p.Server, p.RTT.String()}}
+}
+
+// WriteText writes "pong".
+func (p PingResult) WriteText(w io.Writer) error {
+	_, err := fmt.Fprintln(w, "pong")
+	return err
 }
 
-// Reply handles replies from gonzo.
-func (app *App) Reply(msg osc.Message) error {
-	addr, err := msg.Arguments[0].ReadString()
+// Ping sends a ping message and waits for the pong.
+func (app *App) Ping(inv *Invocation) error {
+	rtt, err := app.client.Ping(app.ctx)
 	if err != nil {
-		return errors.Wrap(err, "reading first argument of reply")
+		return errors.Wrap(err, "pinging gonzo")
 	}
-	app.debugf("received reply for %s", addr)
-	app.replies <- msg
-	return nil
+	return errors.Wrap(app.print(PingResult{
+		Server: app.client.Conn().RemoteAddr().String(),
+		RTT:    rtt,
+	}), "printing pong")
 }
 
 // Run runs the application.
 func (app *App) Run() error {
-	app.Go(app.ServeOSC)
 	app.Go(app.run)
 
-	app.debugf("initialized connection laddr=%s raddr=%s\n", app.LocalAddr(), app.RemoteAddr())
-
 	return app.Wait()
 }
 
-// ServeOSC listens for osc methods to be invoked.
+// ServeOSC waits for the client to stop serving the connection to gonzo,
+// e.g. because gonzo is not running, and returns the reason.
 func (app *App) ServeOSC() error {
-	if err := app.Serve(app.dispatcher()); err != nil {
-		app.debugf("ServeOSC error %s", err)
-		return err
-	}
-	return nil
+	<-app.client.Done()
+	return app.client.Err()
 }
 
 // Wait waits for all the goroutines in an errgroup.Group
@@ -137,29 +107,6 @@ func (app *App) Wait() error {
 	return err
 }
 
-// WithCancel returns an osc method that calls the provided osc method and then cancels the app.
-func (app *App) WithCancel(m osc.Method) osc.Method {
-	return func(msg osc.Message) error {
-		err := m(msg)
-		app.cancel()
-		return err
-	}
-}
-
-// commands returns a map from command names to the functions that handle the commands.
-func (app *App) commands() map[string]cmdFunc {
-	return map[string]cmdFunc{
-		"add":  withDone(app.Add),
-		"help": withDone(usageCmd),
-		"lc":   withDone(app.ListClients),
-		"logs": withDone(app.ClientLogs),
-		"ls":   withDone(app.ListSessions),
-		"new":  withDone(app.NewSession),
-		"rm":   withDone(app.RemoveSession),
-		"ping": app.Ping,
-	}
-}
-
 // debug prints a debug message.
 func (app *App) debug(msg string) {
 	if app.Debug {
@@ -174,71 +121,66 @@ func (app *App) debugf(format string, args ...interface{}) {
 	}
 }
 
-// dispatcher returns an osc dispatcher that handles replies from gonzo.
-func (app *App) dispatcher() osc.Dispatcher {
-	return osc.Dispatcher{
-		nsm.AddressError: app.Error,
-		"/pong":          app.WithCancel(app.Pong),
-		nsm.AddressReply: app.Reply,
+// connect connects to gonzo, unless the app is already connected.
+// The app stops with the reason when the client stops serving the connection.
+func (app *App) connect() error {
+	if app.client != nil {
+		return nil
 	}
+	if err := app.dial(); err != nil {
+		return err
+	}
+	app.Go(app.ServeOSC)
+	return nil
 }
 
-// initialize initializes the application.
-func (app *App) initialize() error {
-	// Initialize the OSC connection.
-	a := net.JoinHostPort(app.Host, strconv.Itoa(app.Port))
-	raddr, err := net.ResolveUDPAddr("udp", a)
-	if err != nil {
-		return errors.Wrap(err, "could not resolve remote udp address")
+// dial creates the client for the gonzo server in the config.
+// Local commands that can use gonzo but do not need it, like tab completion, only dial.
+func (app *App) dial() error {
+	if app.client != nil {
+		return nil
 	}
-	laddr, err := net.ResolveUDPAddr("udp", "0.0.0.0:0")
-	if err != nil {
-		return errors.Wrap(err, "could not resolve local udp address")
+	if app.contextErr != nil {
+		return app.contextErr
 	}
-	conn, err := osc.DialUDPContext(app.ctx, "udp", laddr, raddr)
+	client, err := gonzo.Dial(app.ctx, app.Host, app.Port, app.Timeout)
 	if err != nil {
-		return errors.Wrap(err, "could not listen on udp")
+		return errors.Wrap(err, "connecting to gonzo")
+	}
+	client.Retries = app.Retries
+
+	if app.Debug {
+		client.Logf = log.Printf
 	}
-	app.Conn = conn
+	app.client = client
+
+	conn := client.Conn()
+	app.debugf("initialized connection laddr=%s raddr=%s\n", conn.LocalAddr(), conn.RemoteAddr())
 
 	return nil
 }
 
 // run runs the command we have invoked.
+// It returns ErrDone when the command succeeds, so that the app exits.
 func (app *App) run() error {
-	args := app.flags.Args()
-	if len(args) == 0 {
+	if len(app.args) == 0 {
 		return fmt.Errorf("%s needs a command", os.Args[0])
 	}
-	var (
-		command  = args[0]
-		commands = app.commands()
-	)
-	run, ok := commands[command]
-	if !ok {
-		return errors.New("unrecognized command: " + command)
+	if err := app.runCommand(app.args); err != nil {
+		return err
 	}
-	return run(args[1:])
+	return ErrDone
 }
 
-// withDone returns a cmdFunc that returns ErrDone if f returns
-// nil, and otherwise returns the error that f returns.
-func withDone(f cmdFunc) cmdFunc {
-	return func(args []string) error {
-		if err := f(args); err != nil {
-			return err
-		}
-		return ErrDone
-	}
+// keepServing tells the client to keep going if the gonzo server goes away.
+func (app *App) keepServing() {
+	app.client.KeepServing()
 }
 
 func init() {
-	commandUsage["ping"] = func() error {
-		fmt.Fprintf(os.Stderr, "Ping a gonzo server.\n")
-		fmt.Fprintf(os.Stderr, "\n")
-		fmt.Fprintf(os.Stderr, "Usage:\n")
-		fmt.Fprintf(os.Stderr, "gonzoctl ping\n")
-		fmt.Fprintf(os.Stderr, "\n")
-		return nil
-	}
+	registerCommand(&Command{
+		Name:    "ping",
+		Summary: "Ping a gonzo server.",
+		Run:     (*App).Ping,
+	})
 }
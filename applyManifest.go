@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/gonzoctl/gonzo"
+	"github.com/scgolang/nsm"
+)
+
+// ApplyManifest makes the current session match a manifest.
+// It creates or opens the session, then adds clients and shows or hides their GUI.
+// It never removes clients, it warns about the ones that differ from the manifest.
+func (app *App) ApplyManifest(inv *Invocation) error {
+	m, err := readManifest(inv.String("f"))
+	if err != nil {
+		return err
+	}
+	sessions, current, err := app.client.Sessions(app.ctx)
+	if err != nil {
+		return err
+	}
+	if change, ok := planSession(m, sessions, current); ok {
+		if err := app.applyChange(change); err != nil {
+			return err
+		}
+	}
+	clients, err := app.client.Clients(app.ctx)
+	if err != nil {
+		return err
+	}
+	for _, change := range planClients(m, clients) {
+		if !change.applicable() {
+			log.Printf("gonzo can not remove clients, skipping: %s", change)
+			continue
+		}
+		if err := app.applyChange(change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyChange sends a change to gonzo.
+func (app *App) applyChange(change Change) error {
+	app.debugf("applying: %s", change)
+
+	switch change.Op {
+	case opNew:
+		return app.client.NewSession(app.ctx, change.Session)
+	case opOpen:
+		return app.client.OpenSession(app.ctx, change.Session)
+	case opAdd:
+		return app.client.Add(app.ctx, change.Client, change.Executable)
+	case opShow, opHide:
+		return app.applyGUI(change.Client, change.Op == opShow)
+	}
+	return errors.Errorf("can not apply %s", change)
+}
+
+// applyGUIWait is how long apply waits for a client that was just added to announce itself.
+const applyGUIWait = 5 * time.Second
+
+// applyGUI shows or hides the GUI of a client.
+// Clients that were just added do not have capabilities until they announce themselves,
+// so it waits up to applyGUIWait for them. The GUI of a client without the optional-gui
+// capability is left alone with a warning.
+func (app *App) applyGUI(name string, show bool) error {
+	var (
+		poll     = time.NewTicker(waitPollInterval)
+		deadline = time.NewTimer(applyGUIWait)
+	)
+	defer poll.Stop()
+	defer deadline.Stop()
+
+	for {
+		clients, err := app.client.Clients(app.ctx)
+		if err != nil {
+			return err
+		}
+		client, ok := gonzo.FindClient(clients, name)
+		if !ok {
+			return errors.Errorf("no such client: %s", name)
+		}
+		switch {
+		case client.HasCapability(nsm.CapGUI):
+			if client.GUIVisible() == show {
+				return nil
+			}
+			return app.client.SetGUI(app.ctx, name, show)
+		case len(client.Capabilities) > 0:
+			log.Printf("client %s does not have the %s capability, skipping its gui", name, nsm.CapGUI)
+			return nil
+		}
+		select {
+		case <-app.ctx.Done():
+			return app.ctx.Err()
+		case <-deadline.C:
+			log.Printf("client %s did not announce itself within %s, skipping its gui", name, applyGUIWait)
+			return nil
+		case <-poll.C:
+		}
+	}
+}
+
+func init() {
+	registerCommand(&Command{
+		Name:    "apply",
+		Summary: "Make the current session match a session manifest.",
+		Flags:   []Flag{manifestFlag},
+		Help: func(w io.Writer) {
+			fmt.Fprintf(w, "apply creates the session if it does not exist and opens it if it is not the\n")
+			fmt.Fprintf(w, "current session. Then it adds the clients that are missing and shows or hides\n")
+			fmt.Fprintf(w, "their GUI. Only the changes that are needed are sent to gonzo, see gonzoctl diff.\n")
+			fmt.Fprintf(w, "Clients that apply adds get %s to announce themselves before their GUI is shown or hidden.\n", applyGUIWait)
+			fmt.Fprintf(w, "\n")
+			fmt.Fprintf(w, "apply never removes clients, because gonzo can not remove them. Clients that are\n")
+			fmt.Fprintf(w, "not in the manifest, or that run a different executable, are left alone and apply\n")
+			fmt.Fprintf(w, "prints a warning for each of them. gonzoctl diff lists them too.\n")
+			fmt.Fprintf(w, "\n")
+			writeManifestHelp(w)
+			fmt.Fprintf(w, "\n")
+			fmt.Fprintf(w, "Example:\n")
+			fmt.Fprintf(w, "gonzoctl get session > show.yaml\n")
+			fmt.Fprintf(w, "gonzoctl apply -f show.yaml\n")
+		},
+		Run: (*App).ApplyManifest,
+	})
+}
@@ -1,118 +1,106 @@
 package main
 
 import (
-	"flag"
 	"fmt"
-	"os"
+	"io"
 	"time"
 
 	"github.com/pkg/errors"
-	"github.com/scgolang/nsm"
-	"github.com/scgolang/osc"
+	"github.com/scgolang/gonzoctl/gonzo"
 )
 
-// logOutputOptions maps the CLI options for gonzoctl to the appropriate values in the gonzo OSC API.
-var logOutputOptions = map[string]int32{"stderr": 2, "stdout": 1}
+// logOutputOptions are the values of the -o option of the logs command.
+var logOutputOptions = map[string]bool{gonzo.StreamStderr: true, gonzo.StreamStdout: true}
 
-// ClientLogs gets the logs of a client.
-func (app *App) ClientLogs(args []string) error {
-	if minimum, got := 1, len(args); got < minimum {
-		return errors.Errorf("expected at least %d argument(s), got %d", minimum, got)
-	}
-	var (
-		fs         = flag.NewFlagSet("logs", flag.ExitOnError)
-		outputFlag string
-	)
-	fs.StringVar(&outputFlag, "o", "stderr", "Output stream.")
-
-	if err := fs.Parse(args); err != nil {
-		return errors.Wrap(err, "parsing flags for logs command")
-	}
-	if expected, got := 1, len(fs.Args()); expected != got {
-		return errors.New("expected client name in logs command")
-	}
-	clientName := fs.Args()[0]
+// Logs is the result of the logs command.
+type Logs struct {
+	Client string   `json:"client"`
+	Stream string   `json:"stream"`
+	Lines  []string `json:"lines"`
+}
 
-	var outputArg int32
-	outputArg, outputOK := logOutputOptions[outputFlag]
-	if !outputOK {
-		return errors.Errorf("expected output option to be either stderr or stdout")
-	}
-	if err := app.Send(osc.Message{
-		Address: nsm.AddressClientLogs,
-		Arguments: osc.Arguments{
-			osc.String(clientName),
-			osc.Int(outputArg),
-		},
-	}); err != nil {
-		return errors.Wrap(err, "sending message to get client logs")
+// Rows returns a row for each log line.
+func (l Logs) Rows() ([]string, [][]string) {
+	rows := make([][]string, len(l.Lines))
+	for i, line := range l.Lines {
+		rows[i] = []string{l.Client, l.Stream, line}
 	}
+	return []string{"CLIENT", "STREAM", "LINE"}, rows
+}
 
-	app.debug("waiting for reply")
-
-	select {
-	case errReply := <-app.errors:
-		app.debug("got error " + errReply.Error())
-	case reply := <-app.replies:
-		return errors.Wrap(app.printClientLogs(clientName, reply), "printing client logs")
-	case <-time.After(app.Timeout):
-		return errors.New("timeout")
+// WriteText writes the log lines.
+func (l Logs) WriteText(w io.Writer) error {
+	for _, line := range l.Lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return errors.Wrap(err, "printing log line")
+		}
 	}
 	return nil
 }
 
-// printClientLogs prints log messages for a client from an OSC message.
-func (app *App) printClientLogs(expectedClientName string, msg osc.Message) error {
-	const minimumNumArgs = 3
-
-	app.debugf("printing logs from %#v", msg)
+// ClientLogs gets the logs of a client.
+func (app *App) ClientLogs(inv *Invocation) error {
+	var (
+		follow     = inv.Bool("f")
+		outputFlag = inv.String("o")
+	)
+	if !logOutputOptions[outputFlag] {
+		return usageErrorf("expected output option to be either stderr or stdout")
+	}
+	backlog := logBacklog{numLines: inv.Int("n"), since: inv.Duration("since")}
 
-	if minimum, got := minimumNumArgs, len(msg.Arguments); got < minimum {
-		return errors.Errorf("expected at least %d arguments, got %d", minimum, got)
+	if inv.Bool("all") {
+		if len(inv.Args) > 0 {
+			return usageErrorf("logs --all does not take a client name")
+		}
+		// Show both streams unless the user picked one.
+		streams := []string{gonzo.StreamStderr, gonzo.StreamStdout}
+		if inv.IsSet("o") {
+			streams = []string{outputFlag}
+		}
+		return app.allLogs(streams, follow, backlog)
 	}
-	addr, err := msg.Arguments[0].ReadString()
-	if err != nil {
-		return errors.Wrap(err, "reading reply address")
+	if len(inv.Args) == 0 {
+		return usageErrorf("expected client name in logs command")
 	}
-	if expected, got := nsm.AddressClientLogs, addr; expected != got {
-		return errors.Errorf("expected %s, got %s", expected, got)
+	clientName := inv.Args[0]
+
+	if follow {
+		return app.followLogs(clientName, outputFlag, backlog)
 	}
-	clientName, err := msg.Arguments[1].ReadString()
+	logs, err := app.clientLogs(clientName, outputFlag)
 	if err != nil {
-		return errors.Wrap(err, "reading client name")
+		return err
 	}
-	if clientName != expectedClientName {
-		return errors.Errorf("expected client name %s, got %s", expectedClientName, clientName)
-	}
-	numLines, err := msg.Arguments[2].ReadInt32()
+	logs.Lines = backlog.filter(logs.Lines, time.Now())
+
+	return errors.Wrap(app.print(logs), "printing client logs")
+}
+
+// clientLogs gets the logs of a client.
+// stream must be one of the keys of logOutputOptions.
+func (app *App) clientLogs(clientName, stream string) (Logs, error) {
+	lines, err := app.client.Logs(app.ctx, clientName, stream)
 	if err != nil {
-		return errors.Wrap(err, "reading num log lines")
+		return Logs{}, err
 	}
-	if expected, got := numLines, int32(len(msg.Arguments)-minimumNumArgs); expected != got {
-		return errors.Errorf("expected %d log lines, got %d", expected, got)
-	}
-	for i := int32(0); i < numLines; i++ {
-		line, err := msg.Arguments[i+minimumNumArgs].ReadString()
-		if err != nil {
-			return errors.Wrap(err, "reading log line")
-		}
-		if _, err := fmt.Println(line); err != nil {
-			return errors.Wrap(err, "printing log line")
-		}
-	}
-	return nil
+	return Logs{Client: clientName, Stream: stream, Lines: lines}, nil
 }
 
 func init() {
-	commandUsage["logs"] = func() error {
-		fmt.Fprintf(os.Stderr, "Get the logs of a gonzo client.\n")
-		fmt.Fprintf(os.Stderr, "\n")
-		fmt.Fprintf(os.Stderr, "Usage:\n")
-		fmt.Fprintf(os.Stderr, "gonzoctl logs [OPTIONS] NAME\n")
-		fmt.Fprintf(os.Stderr, "\n")
-		fmt.Fprintf(os.Stderr, "OPTIONS\n")
-		fmt.Fprintf(os.Stderr, "-o stderr|stdout             Show either the client's stderr (default) or stdout.\n")
-		fmt.Fprintf(os.Stderr, "\n")
-		return nil
-	}
+	registerCommand(&Command{
+		Name:    "logs",
+		Summary: "Get the logs of a gonzo client.",
+		Args: []Arg{
+			{Name: "NAME", Help: "The name of the client, leave it out with --all.", Optional: true, Complete: (*App).clientNames},
+		},
+		Flags: []Flag{
+			{Name: "o", Value: "stderr|stdout", Default: gonzo.StreamStderr, Help: "Show either the client's stderr (default) or stdout.", Complete: func(app *App) []string { return sortedKeys(logOutputOptions) }},
+			{Name: "all", Default: false, Help: "Show the logs of every client in the current session as one stream.\nEach line is prefixed with the client name and stream.\nBoth stderr and stdout are shown unless -o is given."},
+			{Name: "f", Default: false, Help: "Keep printing new log lines as the client writes them."},
+			{Name: "n", Value: "N", Default: -1, Help: "Only show the last N lines of the existing logs."},
+			{Name: "since", Value: "DURATION", Default: time.Duration(0), Help: "Only show existing lines that are newer than DURATION, e.g. 10m.\nLines are dated by a leading timestamp, lines without one\nhave the date of the line before them."},
+		},
+		Run: (*App).ClientLogs,
+	})
 }
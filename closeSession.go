@@ -0,0 +1,14 @@
+package main
+
+// CloseSession saves and closes the current session.
+func (app *App) CloseSession(inv *Invocation) error {
+	return app.client.CloseSession(app.ctx)
+}
+
+func init() {
+	registerCommand(&Command{
+		Name:    "close",
+		Summary: "Save and close the current session.",
+		Run:     (*App).CloseSession,
+	})
+}
@@ -0,0 +1,652 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Command is a gonzoctl command.
+// Help, argument checks, flag parsing and tab completion are all generated from it.
+type Command struct {
+	Name    string
+	Aliases []string
+
+	// Summary is a single sentence that describes the command.
+	Summary string
+
+	Args  []Arg
+	Flags []Flag
+
+	// Help writes anything else that should be in the help of the command, e.g. examples.
+	Help func(w io.Writer)
+
+	// Subcommands are commands that are run as gonzoctl NAME SUBCOMMAND.
+	// A command with subcommands has no Run function of its own.
+	Subcommands []*Command
+
+	// Hidden commands are left out of help and completion.
+	Hidden bool
+
+	// RawArgs commands get their arguments as they are, without parsing flags.
+	RawArgs bool
+
+	// Local commands do not talk to gonzo, so they run without connecting to it.
+	// Subcommands of a local command are local too.
+	Local bool
+
+	Run func(app *App, inv *Invocation) error
+
+	parent *Command
+}
+
+// Arg is a positional argument of a command.
+type Arg struct {
+	Name string
+	Help string
+
+	// Optional arguments can be left out.
+	Optional bool
+
+	// Variadic is only valid for the last argument, which can then be repeated.
+	Variadic bool
+
+	// Complete returns the candidates for tab completion.
+	Complete func(app *App) []string
+}
+
+// Flag is a flag of a command.
+type Flag struct {
+	Name string
+
+	// Value is the name of the flag's value in help, e.g. DURATION.
+	// It is empty for bool flags.
+	Value string
+
+	// Default is the default value, which must be a bool, an int, a string, a time.Duration
+	// or an empty []string. The type of Default is the type of the flag.
+	// []string flags can be given more than once.
+	Default interface{}
+
+	// Help describes the flag, it can have several lines.
+	Help string
+
+	// Complete returns the candidates for the flag's value for tab completion.
+	Complete func(app *App) []string
+}
+
+// Invocation is a command with its arguments and the values of its flags.
+type Invocation struct {
+	Command *Command
+	Args    []string
+
+	flags *flag.FlagSet
+}
+
+// Bool returns the value of a bool flag.
+func (inv *Invocation) Bool(name string) bool {
+	return inv.value(name).(bool)
+}
+
+// Duration returns the value of a time.Duration flag.
+func (inv *Invocation) Duration(name string) time.Duration {
+	return inv.value(name).(time.Duration)
+}
+
+// Int returns the value of an int flag.
+func (inv *Invocation) Int(name string) int {
+	return inv.value(name).(int)
+}
+
+// String returns the value of a string flag.
+func (inv *Invocation) String(name string) string {
+	return inv.value(name).(string)
+}
+
+// Strings returns the values of a []string flag.
+func (inv *Invocation) Strings(name string) []string {
+	return inv.value(name).([]string)
+}
+
+// IsSet returns true if a flag was given on the command line.
+func (inv *Invocation) IsSet(name string) bool {
+	set := false
+	inv.flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// value returns the value of a flag.
+// It panics if the command does not have the flag, which is a programming error.
+func (inv *Invocation) value(name string) interface{} {
+	f := inv.flags.Lookup(name)
+	if f == nil {
+		panic(fmt.Sprintf("%s command has no %s flag", inv.Command.FullName(), name))
+	}
+	return f.Value.(flag.Getter).Get()
+}
+
+// stringsValue is the value of a flag that can be given more than once.
+type stringsValue []string
+
+// String returns the values separated by commas.
+func (v *stringsValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return strings.Join(*v, ",")
+}
+
+// Set adds a value.
+func (v *stringsValue) Set(s string) error {
+	*v = append(*v, s)
+	return nil
+}
+
+// Get returns the values.
+func (v *stringsValue) Get() interface{} {
+	return []string(*v)
+}
+
+// commands has the registered commands, by name and by alias.
+var commands = map[string]*Command{}
+
+// registerCommand registers a command.
+// It is called from init functions, so it panics if the command is not valid.
+func registerCommand(cmd *Command) {
+	for _, name := range append([]string{cmd.Name}, cmd.Aliases...) {
+		if _, ok := commands[name]; ok {
+			panic("command registered twice: " + name)
+		}
+		commands[name] = cmd
+	}
+	cmd.validate()
+}
+
+// validate checks a command and its subcommands, and links subcommands to their parent.
+func (cmd *Command) validate() {
+	if (cmd.Run == nil) == (len(cmd.Subcommands) == 0) {
+		panic(cmd.FullName() + " command must have either a Run function or subcommands")
+	}
+	for i, arg := range cmd.Args {
+		if arg.Variadic && i != len(cmd.Args)-1 {
+			panic(cmd.FullName() + " command has a variadic argument that is not the last argument")
+		}
+	}
+	for _, f := range cmd.Flags {
+		switch f.Default.(type) {
+		case bool, int, string, time.Duration:
+		case []string:
+			if len(f.Default.([]string)) > 0 {
+				panic(fmt.Sprintf("%s command has a []string flag with a default: %s", cmd.FullName(), f.Name))
+			}
+		default:
+			panic(fmt.Sprintf("%s command has a flag with an unsupported type: %s", cmd.FullName(), f.Name))
+		}
+	}
+	for _, sub := range cmd.Subcommands {
+		sub.parent = cmd
+		sub.validate()
+	}
+}
+
+// findCommand finds a command by name or alias.
+func findCommand(name string) (*Command, bool) {
+	cmd, ok := commands[name]
+	return cmd, ok
+}
+
+// visibleCommands returns the commands that are not hidden, sorted by name.
+func visibleCommands() []*Command {
+	cmds := []*Command{}
+	for name, cmd := range commands {
+		if name == cmd.Name && !cmd.Hidden {
+			cmds = append(cmds, cmd)
+		}
+	}
+	sort.Slice(cmds, func(i, j int) bool {
+		return cmds[i].Name < cmds[j].Name
+	})
+	return cmds
+}
+
+// FullName returns the name of the command, prefixed with the names of its parents.
+func (cmd *Command) FullName() string {
+	if cmd.parent == nil {
+		return cmd.Name
+	}
+	return cmd.parent.FullName() + " " + cmd.Name
+}
+
+// subcommand finds a subcommand by name or alias.
+func (cmd *Command) subcommand(name string) (*Command, bool) {
+	for _, sub := range cmd.Subcommands {
+		if sub.Name == name {
+			return sub, true
+		}
+		for _, alias := range sub.Aliases {
+			if alias == name {
+				return sub, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// subcommandNames returns the names of the subcommands.
+func (cmd *Command) subcommandNames() []string {
+	names := make([]string, len(cmd.Subcommands))
+	for i, sub := range cmd.Subcommands {
+		names[i] = sub.Name
+	}
+	return names
+}
+
+// resolve finds the subcommand that args select, if the command has subcommands.
+// It returns the command that runs and the rest of the arguments.
+func (cmd *Command) resolve(args []string) (*Command, []string, error) {
+	for len(cmd.Subcommands) > 0 {
+		if len(args) == 0 {
+			return nil, nil, usageErrorf("%s: expected one of %s", cmd.FullName(), strings.Join(cmd.subcommandNames(), ", "))
+		}
+		sub, ok := cmd.subcommand(args[0])
+		if !ok {
+			return nil, nil, usageErrorf("%s: expected one of %s, got %s", cmd.FullName(), strings.Join(cmd.subcommandNames(), ", "), args[0])
+		}
+		cmd, args = sub, args[1:]
+	}
+	return cmd, args, nil
+}
+
+// flag finds a flag of the command.
+func (cmd *Command) flag(name string) (Flag, bool) {
+	for _, f := range cmd.Flags {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Flag{}, false
+}
+
+// flagNames returns the flags of the command the way they are written on the command line.
+func (cmd *Command) flagNames() []string {
+	names := make([]string, len(cmd.Flags))
+	for i, f := range cmd.Flags {
+		names[i] = f.flagName()
+	}
+	return names
+}
+
+// flagSet returns a flag set with the flags of the command.
+func (cmd *Command) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.FullName(), flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+
+	for _, f := range cmd.Flags {
+		switch value := f.Default.(type) {
+		case bool:
+			fs.Bool(f.Name, value, f.Help)
+		case int:
+			fs.Int(f.Name, value, f.Help)
+		case string:
+			fs.String(f.Name, value, f.Help)
+		case time.Duration:
+			fs.Duration(f.Name, value, f.Help)
+		case []string:
+			fs.Var(&stringsValue{}, f.Name, f.Help)
+		}
+	}
+	return fs
+}
+
+// parse parses the flags and checks the arguments of the command.
+// It returns flag.ErrHelp if -h or -help is given.
+func (cmd *Command) parse(args []string) (*Invocation, error) {
+	fs := cmd.flagSet()
+
+	if cmd.RawArgs {
+		return &Invocation{Command: cmd, Args: args, flags: fs}, nil
+	}
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		return nil, err
+	} else if err != nil {
+		return nil, usageErrorf("parsing flags for %s command: %s", cmd.FullName(), err)
+	}
+	if err := cmd.checkArgs(fs.Args()); err != nil {
+		return nil, err
+	}
+	return &Invocation{Command: cmd, Args: fs.Args(), flags: fs}, nil
+}
+
+// checkArgs checks the number of positional arguments.
+func (cmd *Command) checkArgs(args []string) error {
+	var (
+		minimum = 0
+		maximum = len(cmd.Args)
+		got     = len(args)
+	)
+	for _, arg := range cmd.Args {
+		if !arg.Optional {
+			minimum++
+		}
+		if arg.Variadic {
+			maximum = -1
+		}
+	}
+	switch {
+	case minimum == maximum && got != minimum:
+		return usageErrorf("%s: expected %d arguments, got %d", cmd.FullName(), minimum, got)
+	case got < minimum:
+		return usageErrorf("%s: expected at least %d arguments, got %d", cmd.FullName(), minimum, got)
+	case maximum >= 0 && got > maximum:
+		return usageErrorf("%s: expected at most %d arguments, got %d", cmd.FullName(), maximum, got)
+	}
+	return nil
+}
+
+// runCommand runs a command line, which starts with the name of the command.
+func (app *App) runCommand(args []string) error {
+	if len(args) == 0 {
+		return usageErrorf("expected a command")
+	}
+	cmd, ok := findCommand(args[0])
+	if !ok {
+		return usageErrorf("unrecognized command: %s", args[0])
+	}
+	cmd, args, err := cmd.resolve(args[1:])
+	if err != nil {
+		return err
+	}
+	inv, err := cmd.parse(args)
+	if err == flag.ErrHelp {
+		cmd.writeHelp(os.Stderr)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !cmd.isLocal() {
+		if err := app.connect(); err != nil {
+			return err
+		}
+	}
+	return cmd.Run(app, inv)
+}
+
+// isLocal returns true if the command or one of its parents is local.
+func (cmd *Command) isLocal() bool {
+	for ; cmd != nil; cmd = cmd.parent {
+		if cmd.Local {
+			return true
+		}
+	}
+	return false
+}
+
+// usageLine returns the synopsis of the command, e.g. gonzoctl logs [OPTIONS] NAME.
+func (cmd *Command) usageLine() string {
+	words := []string{"gonzoctl", cmd.FullName()}
+	if len(cmd.Flags) > 0 {
+		words = append(words, "[OPTIONS]")
+	}
+	for _, arg := range cmd.Args {
+		word := arg.Name
+		if arg.Variadic {
+			word += "..."
+		}
+		if arg.Optional {
+			word = "[" + word + "]"
+		}
+		words = append(words, word)
+	}
+	return strings.Join(words, " ")
+}
+
+// writeHelp writes the help of the command.
+func (cmd *Command) writeHelp(w io.Writer) {
+	fmt.Fprintf(w, "%s\n", cmd.Summary)
+	fmt.Fprintf(w, "\n")
+	fmt.Fprintf(w, "Usage:\n")
+
+	if len(cmd.Subcommands) > 0 {
+		for _, sub := range cmd.Subcommands {
+			fmt.Fprintf(w, "%s\n", sub.usageLine())
+		}
+		fmt.Fprintf(w, "\n")
+		fmt.Fprintf(w, "Commands:\n")
+		for _, sub := range cmd.Subcommands {
+			fmt.Fprintf(w, "%-10s%s\n", sub.Name, sub.Summary)
+		}
+		fmt.Fprintf(w, "\n")
+		fmt.Fprintf(w, "Use gonzoctl help %s COMMAND for the options of each command.\n", cmd.FullName())
+	} else {
+		fmt.Fprintf(w, "%s\n", cmd.usageLine())
+	}
+	if len(cmd.Aliases) > 0 {
+		fmt.Fprintf(w, "\n")
+		fmt.Fprintf(w, "Aliases: %s\n", strings.Join(cmd.Aliases, ", "))
+	}
+	cmd.writeArgs(w)
+	cmd.writeFlags(w)
+
+	fmt.Fprintf(w, "\n")
+	if cmd.Help != nil {
+		cmd.Help(w)
+	}
+}
+
+// writeArgs writes the descriptions of the arguments.
+func (cmd *Command) writeArgs(w io.Writer) {
+	written := false
+	for _, arg := range cmd.Args {
+		if arg.Help == "" {
+			continue
+		}
+		if !written {
+			fmt.Fprintf(w, "\n")
+			written = true
+		}
+		fmt.Fprintf(w, "%-9s %s\n", arg.Name, arg.Help)
+	}
+}
+
+// writeFlags writes the descriptions of the flags.
+func (cmd *Command) writeFlags(w io.Writer) {
+	if len(cmd.Flags) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n")
+	fmt.Fprintf(w, "OPTIONS\n")
+
+	for _, f := range cmd.Flags {
+		name := f.flagName()
+		if f.Value != "" {
+			name += " " + f.Value
+		}
+		for i, line := range strings.Split(f.Help, "\n") {
+			if i > 0 {
+				name = ""
+			}
+			fmt.Fprintf(w, "%-28s %s\n", name, line)
+		}
+	}
+}
+
+// flagName returns the flag the way it is written on the command line:
+// single letter flags have one dash and longer flags have two.
+func (f Flag) flagName() string {
+	if len(f.Name) == 1 {
+		return "-" + f.Name
+	}
+	return "--" + f.Name
+}
+
+// takesValue returns true if the flag needs a value.
+func (f Flag) takesValue() bool {
+	_, isBool := f.Default.(bool)
+	return !isBool
+}
+
+// helpCommand prints help for the program or for a command.
+func helpCommand(app *App, inv *Invocation) error {
+	if len(inv.Args) == 0 {
+		usage()
+		return nil
+	}
+	cmd, ok := findCommand(inv.Args[0])
+	if !ok {
+		return usageErrorf("unrecognized command: %s", inv.Args[0])
+	}
+	for _, name := range inv.Args[1:] {
+		sub, ok := cmd.subcommand(name)
+		if !ok {
+			return usageErrorf("unrecognized command: %s %s", cmd.FullName(), name)
+		}
+		cmd = sub
+	}
+	cmd.writeHelp(os.Stderr)
+	return nil
+}
+
+// splitGlobalFlags takes the global flags out of a command line that starts with
+// the name of a command, so that global flags can be given after the command.
+// Flags that the command has are left alone.
+func splitGlobalFlags(fs *flag.FlagSet, args []string) (globals []string, rest []string) {
+	if len(args) == 0 {
+		return nil, args
+	}
+	cmd, ok := findCommand(args[0])
+	if !ok || cmd.RawArgs {
+		return nil, args
+	}
+	rest = []string{args[0]}
+
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return globals, append(rest, args[i:]...)
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			if sub, ok := cmd.subcommand(arg); ok {
+				cmd = sub
+			}
+			rest = append(rest, arg)
+			continue
+		}
+		name, hasValue := strings.TrimLeft(arg, "-"), false
+		if i := strings.Index(name, "="); i >= 0 {
+			name, hasValue = name[:i], true
+		}
+		if f, ok := cmd.flag(name); ok || fs.Lookup(name) == nil {
+			rest = append(rest, arg)
+			if ok && f.takesValue() && !hasValue && i+1 < len(args) {
+				i++
+				rest = append(rest, args[i])
+			}
+			continue
+		}
+		globals = append(globals, arg)
+		if !hasValue && !isBoolFlag(fs.Lookup(name)) && i+1 < len(args) {
+			i++
+			globals = append(globals, args[i])
+		}
+	}
+	return globals, rest
+}
+
+// completeCommand returns the candidates for the last of a command and its arguments.
+// If globals is not nil, the global flags are completed too.
+func (app *App) completeCommand(words []string, globals *flag.FlagSet) []string {
+	cmd, ok := findCommand(words[0])
+	if !ok || cmd.RawArgs {
+		return nil
+	}
+	i := 1
+	for len(cmd.Subcommands) > 0 {
+		if i == len(words)-1 {
+			return cmd.subcommandNames()
+		}
+		sub, ok := cmd.subcommand(words[i])
+		if !ok {
+			return nil
+		}
+		cmd, i = sub, i+1
+	}
+	var (
+		word = words[len(words)-1]
+		args = 0
+	)
+	for ; i < len(words)-1; i++ {
+		if !strings.HasPrefix(words[i], "-") {
+			args++
+			continue
+		}
+		if strings.Contains(words[i], "=") {
+			continue
+		}
+		var complete func(app *App) []string
+
+		if f, ok := cmd.flag(strings.TrimLeft(words[i], "-")); ok {
+			if !f.takesValue() {
+				continue
+			}
+			complete = f.Complete
+		} else if globals != nil && isValueFlag(globals, words[i]) {
+			flag := words[i]
+			complete = func(app *App) []string { return completeGlobalFlag(flag) }
+		} else {
+			continue
+		}
+		if i == len(words)-2 {
+			if complete == nil {
+				return nil
+			}
+			return complete(app)
+		}
+		i++
+	}
+	if strings.HasPrefix(word, "-") {
+		names := cmd.flagNames()
+		if globals != nil {
+			for _, name := range globalFlagNames(globals) {
+				if _, ok := cmd.flag(strings.TrimLeft(name, "-")); !ok {
+					names = append(names, name)
+				}
+			}
+		}
+		return names
+	}
+	if len(cmd.Args) == 0 {
+		return nil
+	}
+	if args >= len(cmd.Args) {
+		if last := cmd.Args[len(cmd.Args)-1]; !last.Variadic {
+			return nil
+		}
+		args = len(cmd.Args) - 1
+	}
+	if complete := cmd.Args[args].Complete; complete != nil {
+		return complete(app)
+	}
+	return nil
+}
+
+func init() {
+	registerCommand(&Command{
+		Name:    "help",
+		Summary: "Print the usage message, or the help of a command.",
+		Args: []Arg{
+			{Name: "COMMAND", Optional: true, Variadic: true, Complete: (*App).commandNames},
+		},
+		Local: true,
+		Run:   helpCommand,
+	})
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// completionCacheTTL is how long session and client names are cached for tab completion.
+const completionCacheTTL = 5 * time.Second
+
+// completionScripts are the completion scripts for each shell.
+// They run gonzoctl __complete with the words on the command line,
+// and it prints the candidates for the last word one per line.
+var completionScripts = map[string]string{
+	"bash": `# bash completion for gonzoctl
+# Add this to ~/.bashrc:
+# source <(gonzoctl completion bash)
+
+_gonzoctl() {
+    local IFS=$'\n'
+    COMPREPLY=($(gonzoctl __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _gonzoctl gonzoctl
+`,
+	"zsh": `#compdef gonzoctl
+# zsh completion for gonzoctl
+# Add this to ~/.zshrc after compinit:
+# source <(gonzoctl completion zsh)
+
+_gonzoctl() {
+    local -a candidates
+    candidates=(${(f)"$(gonzoctl __complete "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+    compadd -a candidates
+}
+
+if [ "$funcstack[1]" = "_gonzoctl" ]; then
+    _gonzoctl "$@"
+else
+    compdef _gonzoctl gonzoctl
+fi
+`,
+	"fish": `# fish completion for gonzoctl
+# Save this as ~/.config/fish/completions/gonzoctl.fish:
+# gonzoctl completion fish > ~/.config/fish/completions/gonzoctl.fish
+
+function __gonzoctl_complete
+    set -l words (commandline -opc)
+    set -e words[1]
+    gonzoctl __complete $words (commandline -ct) 2>/dev/null
+end
+
+complete -c gonzoctl -f -a '(__gonzoctl_complete)'
+`,
+}
+
+// completionCache is a file with the names that were fetched from gonzo for tab completion.
+type completionCache struct {
+	Time  time.Time `json:"time"`
+	Names []string  `json:"names"`
+}
+
+// Completion prints a completion script for a shell.
+func (app *App) Completion(inv *Invocation) error {
+	script, ok := completionScripts[inv.Args[0]]
+	if !ok {
+		return usageErrorf("expected shell to be one of %s", strings.Join(sortedKeys(completionShells()), ", "))
+	}
+	_, err := fmt.Print(script)
+	return err
+}
+
+// Complete prints the candidates for the last of args, which are the words
+// on a command line after gonzoctl. It is run by the completion scripts.
+func (app *App) Complete(inv *Invocation) error {
+	args := inv.Args
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	// Names are fetched from the gonzo server the command line is for.
+	if config, err := app.completionConfig(args[:len(args)-1]); err == nil {
+		a := *app
+		a.Config = config
+		app = &a
+	} else {
+		app.debugf("could not parse the global flags: %s", err)
+	}
+	var (
+		word       = args[len(args)-1]
+		words      = skipGlobalFlags(app.flags, args)
+		candidates []string
+	)
+	switch {
+	case len(words) == 0:
+		candidates = completeGlobalFlag(args[len(args)-2])
+	case len(words) == 1 && strings.HasPrefix(word, "-"):
+		candidates = globalFlagNames(app.flags)
+	case len(words) == 1:
+		candidates = app.commandNames()
+	default:
+		candidates = app.completeCommand(words, app.flags)
+	}
+	for _, c := range candidates {
+		if c != "" && strings.HasPrefix(c, word) {
+			fmt.Println(c)
+		}
+	}
+	return nil
+}
+
+// completionConfig returns the config with the global flags in words applied,
+// both the ones before the command and the ones after it.
+func (app *App) completionConfig(words []string) (Config, error) {
+	var (
+		rest     = skipGlobalFlags(app.flags, words)
+		globals  = append([]string{}, words[:len(words)-len(rest)]...)
+		after, _ = splitGlobalFlags(app.flags, rest)
+		fs       = flag.NewFlagSet(app.flags.Name(), flag.ContinueOnError)
+	)
+	fs.SetOutput(ioutil.Discard)
+
+	return parseConfig(fs, append(globals, after...))
+}
+
+// skipGlobalFlags returns the command and its arguments from the words on a command line.
+// It returns no words if the last word is the value of a global flag.
+func skipGlobalFlags(fs *flag.FlagSet, words []string) []string {
+	i := 0
+	for i < len(words)-1 && strings.HasPrefix(words[i], "-") {
+		if isValueFlag(fs, words[i]) {
+			i++
+		}
+		i++
+	}
+	return words[i:]
+}
+
+// completeGlobalFlag completes the value of a global flag.
+func completeGlobalFlag(flag string) []string {
+	switch strings.TrimLeft(flag, "-") {
+	case "o":
+		return sortedKeys(outputFormats)
+	case "context":
+		return contextNames()
+	}
+	return nil
+}
+
+// globalFlagNames returns the names of the global flags with a leading dash.
+func globalFlagNames(fs *flag.FlagSet) []string {
+	names := []string{}
+	fs.VisitAll(func(f *flag.Flag) {
+		names = append(names, "-"+f.Name)
+	})
+	sort.Strings(names)
+	return names
+}
+
+// isValueFlag returns true if word is a flag that takes a value.
+func isValueFlag(fs *flag.FlagSet, word string) bool {
+	if !strings.HasPrefix(word, "-") || strings.Contains(word, "=") {
+		return false
+	}
+	f := fs.Lookup(strings.TrimLeft(word, "-"))
+	return f != nil && !isBoolFlag(f)
+}
+
+// isBoolFlag returns true if a flag does not take a value.
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// cachedNames returns names from the completion cache if they are fresh,
+// and otherwise fetches them and caches them.
+// The cache is kept per gonzo server, so it is shared by completion and the shell.
+func (app *App) cachedNames(kind string, fetch func() []string) []string {
+	path := filepath.Join(completionCacheDir(), fmt.Sprintf("%s-%d-%s.json", app.Host, app.Port, kind))
+
+	if data, err := ioutil.ReadFile(path); err == nil {
+		var cache completionCache
+		if err := json.Unmarshal(data, &cache); err == nil && time.Since(cache.Time) < completionCacheTTL {
+			return cache.Names
+		}
+	}
+	names := fetch()
+	if names == nil {
+		return nil
+	}
+	data, err := json.Marshal(completionCache{Time: time.Now(), Names: names})
+	if err != nil {
+		return names
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		app.debugf("could not create completion cache: %s", err)
+		return names
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		app.debugf("could not write completion cache: %s", err)
+	}
+	return names
+}
+
+// completionCacheDir returns the directory of the completion cache.
+func completionCacheDir() string {
+	dir := os.Getenv("XDG_CACHE_HOME")
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".cache")
+	}
+	return filepath.Join(dir, "gonzoctl")
+}
+
+// completionShells returns the shells there are completion scripts for.
+func completionShells() map[string]bool {
+	shells := map[string]bool{}
+	for shell := range completionScripts {
+		shells[shell] = true
+	}
+	return shells
+}
+
+func init() {
+	registerCommand(&Command{
+		Name:    "completion",
+		Summary: "Print a shell completion script.",
+		Args: []Arg{
+			{Name: "SHELL", Help: "bash, zsh or fish.", Complete: func(app *App) []string { return sortedKeys(completionShells()) }},
+		},
+		Help: func(w io.Writer) {
+			fmt.Fprintf(w, "The script completes commands, flags, session names and client names.\n")
+			fmt.Fprintf(w, "Session and client names are fetched from the gonzo server and cached for %s in %s.\n", completionCacheTTL, completionCacheDir())
+			fmt.Fprintf(w, "\n")
+			fmt.Fprintf(w, "To load completions in the current shell:\n")
+			fmt.Fprintf(w, "bash    source <(gonzoctl completion bash)\n")
+			fmt.Fprintf(w, "zsh     source <(gonzoctl completion zsh)\n")
+			fmt.Fprintf(w, "fish    gonzoctl completion fish | source\n")
+		},
+		Local: true,
+		Run:   (*App).Completion,
+	})
+	registerCommand(&Command{
+		Name:    "__complete",
+		Summary: "Print the candidates for the last word of a command line.",
+		Args: []Arg{
+			{Name: "WORD", Optional: true, Variadic: true},
+		},
+		Hidden:  true,
+		RawArgs: true,
+		Local:   true,
+		Run:     (*App).Complete,
+	})
+}
@@ -3,15 +3,17 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/scgolang/gonzoctl/gonzo"
 )
 
 const (
 	// DefaultPort is the default gonzo port.
-	DefaultPort = 56070
+	DefaultPort = gonzo.DefaultPort
 )
 
 // Config holds the application's configuration.
@@ -20,76 +22,191 @@ type Config struct {
 	Port    int           `json:"port"`
 	Timeout time.Duration `json:"timeout"`
 	Debug   bool          `json:"debug"`
+	Output  string        `json:"output"`
+	Format  string        `json:"format"`
+	Context string        `json:"context"`
+	Retries int           `json:"retries"`
 
 	flags *flag.FlagSet
+
+	// args are the command and its arguments, without the global flags.
+	args []string
+
+	// contextErr is returned when connecting to gonzo if the context does not exist,
+	// so that local commands like context use still work.
+	contextErr error
 }
 
-// NewConfig parses the application's config from command line arguments.
+// NewConfig parses the application's config from command line arguments,
+// the environment and the config file.
+// Flags override the environment, which overrides the config file.
 func NewConfig() (Config, error) {
-	var (
-		config = Config{}
-		fs     = flag.NewFlagSet("gonzoctl", flag.ExitOnError)
-	)
+	fs := flag.NewFlagSet("gonzoctl", flag.ExitOnError)
 	fs.Usage = usage
-	config.flags = fs
 
+	return parseConfig(fs, os.Args[1:])
+}
+
+// parseConfig parses the config from args, the environment and the config file,
+// and defines the global flags in fs.
+func parseConfig(fs *flag.FlagSet, args []string) (Config, error) {
 	defaultTimeout, _ := time.ParseDuration("10s") // Never fails
 
-	fs.StringVar(&config.Host, "host", "127.0.0.1", "Remote host")
-	fs.IntVar(&config.Port, "port", DefaultPort, "Remote port")
-	fs.DurationVar(&config.Timeout, "timeout", defaultTimeout, "Timeout for replies from gonzo server")
-	fs.BoolVar(&config.Debug, "debug", false, "Print debugging information")
+	config := Config{
+		Host:    "127.0.0.1",
+		Port:    DefaultPort,
+		Timeout: defaultTimeout,
+		Output:  OutputText,
+		Retries: gonzo.DefaultRetries,
+	}
+	config.flags = fs
+	config.defineFlags(fs)
 
-	if err := fs.Parse(os.Args[1:]); err != nil {
+	if err := fs.Parse(args); err != nil {
+		return config, errors.Wrap(err, "could not parse config")
+	}
+	// Global flags can also be given after the command.
+	globals, args := splitGlobalFlags(fs, fs.Args())
+	if err := fs.Parse(globals); err != nil {
 		return config, errors.Wrap(err, "could not parse config")
 	}
+	config.args = args
+
+	if !outputFormats[config.Output] {
+		return config, usageErrorf("unrecognized output format: %s", config.Output)
+	}
+	if config.Retries < 0 {
+		return config, usageErrorf("retries must not be negative")
+	}
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	file, err := LoadConfigFile(configFilePath())
+	if err != nil {
+		return config, err
+	}
+	if !set["context"] {
+		config.Context = os.Getenv(EnvContext)
+	}
+	if config.Context != "" {
+		ctx, ok := file.Contexts[config.Context]
+		if !ok {
+			config.contextErr = usageErrorf("no such context: %s", config.Context)
+		}
+		config.apply(ctx, set)
+	} else if ctx, ok := file.Contexts[file.CurrentContext]; ok {
+		config.Context = file.CurrentContext
+		config.apply(ctx, set)
+	}
+	env, err := envContext()
+	if err != nil {
+		return config, err
+	}
+	config.apply(env, set)
+
 	return config, nil
 }
 
+// defineFlags defines the global flags in fs, with the current settings as their defaults.
+func (config *Config) defineFlags(fs *flag.FlagSet) {
+	fs.StringVar(&config.Host, "host", config.Host, "Remote host")
+	fs.IntVar(&config.Port, "port", config.Port, "Remote port")
+	fs.DurationVar(&config.Timeout, "timeout", config.Timeout, "Timeout for replies from gonzo server")
+	fs.BoolVar(&config.Debug, "debug", config.Debug, "Print debugging information")
+	fs.StringVar(&config.Output, "o", config.Output, "Output format (json, yaml, tsv or table)")
+	fs.StringVar(&config.Format, "format", config.Format, "Go template used to print results")
+	fs.StringVar(&config.Context, "context", config.Context, "Named context from the config file")
+	fs.IntVar(&config.Retries, "retries", config.Retries, "Number of times to resend requests that get no reply")
+}
+
+// connectionFlags are the global flags that only take effect when gonzoctl connects to gonzo.
+var connectionFlags = map[string]bool{"context": true, "host": true, "port": true, "retries": true, "timeout": true}
+
+// withGlobalFlags returns the config with the global flags in args applied, and the rest of args.
+// The lines of a script share the connection of the script, so they can not change it.
+func (config Config) withGlobalFlags(args []string) (Config, []string, error) {
+	globals, rest := splitGlobalFlags(config.flags, args)
+	if len(globals) == 0 {
+		return config, rest, nil
+	}
+	fs := flag.NewFlagSet(config.flags.Name(), flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	config.defineFlags(fs)
+
+	if err := fs.Parse(globals); err != nil {
+		return config, nil, usageErrorf("%s", err)
+	}
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		if connectionFlags[f.Name] && err == nil {
+			err = usageErrorf("-%s can not be changed for a single command, give it before the command that runs the script", f.Name)
+		}
+	})
+	if err != nil {
+		return config, nil, err
+	}
+	if !outputFormats[config.Output] {
+		return config, nil, usageErrorf("unrecognized output format: %s", config.Output)
+	}
+	return config, rest, nil
+}
+
+// apply applies the settings in a context, except for the ones that were set with flags.
+func (config *Config) apply(ctx Context, set map[string]bool) {
+	if ctx.Host != "" && !set["host"] {
+		config.Host = ctx.Host
+	}
+	if ctx.Port != 0 && !set["port"] {
+		config.Port = ctx.Port
+	}
+	if ctx.Timeout != 0 && !set["timeout"] {
+		config.Timeout = time.Duration(ctx.Timeout)
+	}
+	if ctx.Debug && !set["debug"] {
+		config.Debug = true
+	}
+}
+
 // usage prints a usage message to stderr.
 func usage() {
 	fmt.Fprintf(os.Stderr, "Usage:\n")
 	fmt.Fprintf(os.Stderr, "gonzoctl [GLOBAL_OPTIONS] COMMAND [COMMAND_OPTIONS]\n")
 	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "Global options can also be given after COMMAND, unless COMMAND has an option with the same name.\n")
+	fmt.Fprintf(os.Stderr, "\n")
 	fmt.Fprintf(os.Stderr, "Global Options:\n")
 	fmt.Fprintf(os.Stderr, "-host HOST              Host or IP of a gonzo server (default is 127.0.0.1).\n")
 	fmt.Fprintf(os.Stderr, "-port PORT              Listening port of a gonzo server (default is 56070).\n")
 	fmt.Fprintf(os.Stderr, "-timeout DURATION       Timeout used when waiting for replies from a gonzo server (default is 10s).\n")
+	fmt.Fprintf(os.Stderr, "-retries N              Number of times to resend a request that gets no reply within the timeout (default is 3).\n")
+	fmt.Fprintf(os.Stderr, "                        Requests that change a session wait for the whole timeout and are only\n")
+	fmt.Fprintf(os.Stderr, "                        resent if they could not be sent, so they never take effect twice.\n")
 	fmt.Fprintf(os.Stderr, "-debug                  Enable debug logging (default is false).\n")
+	fmt.Fprintf(os.Stderr, "-o json|yaml|tsv|table  Print results in a machine-readable format (default is plain text).\n")
+	fmt.Fprintf(os.Stderr, "                        With -o json errors are printed to stderr as JSON objects.\n")
+	fmt.Fprintf(os.Stderr, "-format TEMPLATE        Print results with a Go text/template (overrides -o).\n")
+	fmt.Fprintf(os.Stderr, "-context NAME           Use a named context from the config file (default is the current context).\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "Environment:\n")
+	fmt.Fprintf(os.Stderr, "GONZO_CONTEXT           Named context to use.\n")
+	fmt.Fprintf(os.Stderr, "NSM_URL                 URL of a gonzo server, e.g. osc.udp://10.0.0.2:56070/\n")
+	fmt.Fprintf(os.Stderr, "GONZO_HOST              Host or IP of a gonzo server, overrides NSM_URL.\n")
+	fmt.Fprintf(os.Stderr, "GONZO_PORT              Listening port of a gonzo server, overrides NSM_URL.\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "Flags override the environment, which overrides the context.\n")
+	fmt.Fprintf(os.Stderr, "Contexts are stored in $XDG_CONFIG_HOME/gonzoctl/config.json (see gonzoctl help context).\n")
 	fmt.Fprintf(os.Stderr, "\n")
 	fmt.Fprintf(os.Stderr, "Commands:\n")
-	fmt.Fprintf(os.Stderr, "add             Add a client to the current session.\n")
-	fmt.Fprintf(os.Stderr, "help            Print this usage message.\n")
-	fmt.Fprintf(os.Stderr, "lc              List clients for the current session.\n")
-	fmt.Fprintf(os.Stderr, "logs            Get the logs of a gonzo client.\n")
-	fmt.Fprintf(os.Stderr, "ls              List sessions.\n")
-	fmt.Fprintf(os.Stderr, "new             Create a new session.\n")
-	fmt.Fprintf(os.Stderr, "ping            Ping a gonzo server.\n")
-	fmt.Fprintf(os.Stderr, "rm              Remove a session.\n")
+	for _, cmd := range visibleCommands() {
+		fmt.Fprintf(os.Stderr, "%-16s%s\n", cmd.Name, cmd.Summary)
+	}
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "Exit Status:\n")
+	printExitCodes(os.Stderr)
 	fmt.Fprintf(os.Stderr, "\n")
 	fmt.Fprintf(os.Stderr, "To see usage of a single command do:\n")
 	fmt.Fprintf(os.Stderr, "\n")
 	fmt.Fprintf(os.Stderr, "gonzoctl help COMMAND\n")
 	fmt.Fprintf(os.Stderr, "\n")
 }
-
-var commandUsage = map[string]func() error{}
-
-// usageCmd is for the "help" command.
-func usageCmd(args []string) error {
-	switch len(args) {
-	case 1:
-		// gonzoctl help COMMAND
-		var (
-			cmd = args[0]
-			cu  = commandUsage[cmd]
-		)
-		if cu != nil {
-			return cu()
-		}
-		return errors.New("unrecognized command: " + cmd)
-	default:
-		usage()
-	}
-	return nil
-}
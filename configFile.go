@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Environment variables that configure gonzoctl.
+const (
+	EnvContext = "GONZO_CONTEXT"
+	EnvHost    = "GONZO_HOST"
+	EnvNSMURL  = "NSM_URL"
+	EnvPort    = "GONZO_PORT"
+)
+
+// Duration is a time.Duration that is encoded in JSON as a string, e.g. "10s".
+type Duration time.Duration
+
+// MarshalJSON encodes a duration as a string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON decodes a duration from a string, or from a number of nanoseconds.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var ns int64
+		if err := json.Unmarshal(data, &ns); err != nil {
+			return errors.New("expected duration to be a string or a number")
+		}
+		*d = Duration(ns)
+		return nil
+	}
+	dur, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(dur)
+	return nil
+}
+
+// Context is a named set of settings for connecting to a gonzo server.
+// Zero values mean that the setting is not part of the context.
+type Context struct {
+	Host    string   `json:"host,omitempty"`
+	Port    int      `json:"port,omitempty"`
+	Timeout Duration `json:"timeout,omitempty"`
+	Debug   bool     `json:"debug,omitempty"`
+}
+
+// ConfigFile is the contents of the gonzoctl config file.
+type ConfigFile struct {
+	CurrentContext string             `json:"current_context,omitempty"`
+	Contexts       map[string]Context `json:"contexts"`
+
+	path string
+}
+
+// configFilePath returns the path of the gonzoctl config file.
+func configFilePath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	return filepath.Join(dir, "gonzoctl", "config.json")
+}
+
+// LoadConfigFile loads a config file.
+// If the file does not exist an empty config file is returned.
+func LoadConfigFile(path string) (*ConfigFile, error) {
+	file := &ConfigFile{
+		Contexts: map[string]Context{},
+		path:     path,
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return file, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "reading config file")
+	}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, errors.Wrapf(err, "parsing config file %s", path)
+	}
+	if file.Contexts == nil {
+		file.Contexts = map[string]Context{}
+	}
+	return file, nil
+}
+
+// Save writes the config file.
+func (file *ConfigFile) Save() error {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "encoding config file")
+	}
+	if err := os.MkdirAll(filepath.Dir(file.path), 0755); err != nil {
+		return errors.Wrap(err, "creating config directory")
+	}
+	tmp := file.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return errors.Wrap(err, "writing config file")
+	}
+	return errors.Wrap(os.Rename(tmp, file.path), "writing config file")
+}
+
+// envContext returns the settings from the environment.
+// GONZO_HOST and GONZO_PORT override the host and port in NSM_URL.
+func envContext() (Context, error) {
+	ctx := Context{}
+
+	if nsmURL := os.Getenv(EnvNSMURL); nsmURL != "" {
+		u, err := url.Parse(nsmURL)
+		if err != nil {
+			return ctx, errors.Wrap(err, "parsing "+EnvNSMURL)
+		}
+		ctx.Host = u.Hostname()
+		if p := u.Port(); p != "" {
+			if ctx.Port, err = strconv.Atoi(p); err != nil {
+				return ctx, errors.Wrap(err, "parsing port in "+EnvNSMURL)
+			}
+		}
+	}
+	if host := os.Getenv(EnvHost); host != "" {
+		ctx.Host = host
+	}
+	if port := os.Getenv(EnvPort); port != "" {
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			return ctx, errors.Wrap(err, "parsing "+EnvPort)
+		}
+		ctx.Port = p
+	}
+	return ctx, nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ContextInfo describes a named context.
+type ContextInfo struct {
+	Name    string   `json:"name"`
+	Current bool     `json:"current"`
+	Host    string   `json:"host,omitempty"`
+	Port    int      `json:"port,omitempty"`
+	Timeout Duration `json:"timeout,omitempty"`
+	Debug   bool     `json:"debug,omitempty"`
+}
+
+// ContextList is the result of the context ls command.
+type ContextList []ContextInfo
+
+// Rows returns a row for each context.
+func (l ContextList) Rows() ([]string, [][]string) {
+	rows := make([][]string, len(l))
+	for i, ctx := range l {
+		current, port, timeout := "", "", ""
+		if ctx.Current {
+			current = "*"
+		}
+		if ctx.Port != 0 {
+			port = strconv.Itoa(ctx.Port)
+		}
+		if ctx.Timeout != 0 {
+			timeout = time.Duration(ctx.Timeout).String()
+		}
+		rows[i] = []string{ctx.Name, current, ctx.Host, port, timeout, strconv.FormatBool(ctx.Debug)}
+	}
+	return []string{"NAME", "CURRENT", "HOST", "PORT", "TIMEOUT", "DEBUG"}, rows
+}
+
+// WriteText writes the name of each context, marking the current context with a *.
+func (l ContextList) WriteText(w io.Writer) error {
+	for _, ctx := range l {
+		marker := "   "
+		if ctx.Current {
+			marker = " * "
+		}
+		if _, err := fmt.Fprintln(w, marker+ctx.Name); err != nil {
+			return errors.Wrap(err, "printing context")
+		}
+	}
+	return nil
+}
+
+// ListContexts lists the named contexts in the config file.
+func (app *App) ListContexts(inv *Invocation) error {
+	file, err := LoadConfigFile(configFilePath())
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(app.print(listContexts(file)), "printing contexts")
+}
+
+// UseContext makes a named context the current context.
+func (app *App) UseContext(inv *Invocation) error {
+	file, err := LoadConfigFile(configFilePath())
+	if err != nil {
+		return err
+	}
+	name := inv.Args[0]
+	if _, ok := file.Contexts[name]; !ok {
+		return usageErrorf("no such context: %s", name)
+	}
+	file.CurrentContext = name
+	return file.Save()
+}
+
+// AddContext adds a context to the config file, replacing any context with the same name.
+func (app *App) AddContext(inv *Invocation) error {
+	file, err := LoadConfigFile(configFilePath())
+	if err != nil {
+		return err
+	}
+	file.Contexts[inv.Args[0]] = Context{
+		Host:    inv.String("host"),
+		Port:    inv.Int("port"),
+		Timeout: Duration(inv.Duration("timeout")),
+		Debug:   inv.Bool("debug"),
+	}
+	return file.Save()
+}
+
+// RemoveContext removes a context from the config file.
+func (app *App) RemoveContext(inv *Invocation) error {
+	file, err := LoadConfigFile(configFilePath())
+	if err != nil {
+		return err
+	}
+	name := inv.Args[0]
+	if _, ok := file.Contexts[name]; !ok {
+		return usageErrorf("no such context: %s", name)
+	}
+	delete(file.Contexts, name)
+	if file.CurrentContext == name {
+		file.CurrentContext = ""
+	}
+	return file.Save()
+}
+
+// listContexts returns the contexts in a config file sorted by name.
+func listContexts(file *ConfigFile) ContextList {
+	l := ContextList{}
+	for name, ctx := range file.Contexts {
+		l = append(l, ContextInfo{
+			Name:    name,
+			Current: name == file.CurrentContext,
+			Host:    ctx.Host,
+			Port:    ctx.Port,
+			Timeout: ctx.Timeout,
+			Debug:   ctx.Debug,
+		})
+	}
+	sort.Slice(l, func(i, j int) bool {
+		return l[i].Name < l[j].Name
+	})
+	return l
+}
+
+func init() {
+	contextName := Arg{Name: "NAME", Help: "The name of the context.", Complete: func(app *App) []string { return contextNames() }}
+
+	registerCommand(&Command{
+		Name:    "context",
+		Summary: "Manage named server contexts.",
+		Local:   true,
+		Subcommands: []*Command{
+			{
+				Name:    "ls",
+				Summary: "List contexts.",
+				Run:     (*App).ListContexts,
+			},
+			{
+				Name:    "use",
+				Summary: "Make a context the current context.",
+				Args:    []Arg{contextName},
+				Run:     (*App).UseContext,
+			},
+			{
+				Name:    "add",
+				Summary: "Add a context, or replace the context with the same name.",
+				Args:    []Arg{{Name: "NAME", Help: "The name of the context."}},
+				Flags: []Flag{
+					{Name: "host", Value: "HOST", Default: "", Help: "Host or IP of a gonzo server."},
+					{Name: "port", Value: "PORT", Default: 0, Help: "Listening port of a gonzo server."},
+					{Name: "timeout", Value: "DURATION", Default: time.Duration(0), Help: "Timeout used when waiting for replies from a gonzo server."},
+					{Name: "debug", Default: false, Help: "Enable debug logging."},
+				},
+				Run: (*App).AddContext,
+			},
+			{
+				Name:    "rm",
+				Summary: "Remove a context.",
+				Args:    []Arg{contextName},
+				Run:     (*App).RemoveContext,
+			},
+		},
+		Help: func(w io.Writer) {
+			fmt.Fprintf(w, "Contexts are stored in %s\n", configFilePath())
+			fmt.Fprintf(w, "\n")
+			fmt.Fprintf(w, "Example:\n")
+			fmt.Fprintf(w, "gonzoctl context add --host 10.0.0.2 stage\n")
+			fmt.Fprintf(w, "gonzoctl context use stage\n")
+		},
+	})
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// DiffManifest prints the changes that apply would make for a manifest.
+func (app *App) DiffManifest(inv *Invocation) error {
+	m, err := readManifest(inv.String("f"))
+	if err != nil {
+		return err
+	}
+	changes, err := app.planManifest(m)
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(app.print(changes), "printing changes")
+}
+
+func init() {
+	registerCommand(&Command{
+		Name:    "diff",
+		Summary: "Show the changes that apply would make for a session manifest.",
+		Flags:   []Flag{manifestFlag},
+		Help: func(w io.Writer) {
+			fmt.Fprintf(w, "Each line is a change: + creates a session or adds a client, and ~ opens a session\n")
+			fmt.Fprintf(w, "or shows or hides a GUI. - is a client that is not in the manifest and ! is a client\n")
+			fmt.Fprintf(w, "that runs a different executable than the manifest. gonzo can not remove clients, so\n")
+			fmt.Fprintf(w, "apply leaves those alone. Nothing is printed if the session matches the manifest.\n")
+			fmt.Fprintf(w, "\n")
+			fmt.Fprintf(w, "The clients of a session that is not open are only compared once apply opens it.\n")
+			fmt.Fprintf(w, "\n")
+			writeManifestHelp(w)
+			fmt.Fprintf(w, "\n")
+			fmt.Fprintf(w, "Example:\n")
+			fmt.Fprintf(w, "gonzoctl diff -f show.yaml\n")
+		},
+		Run: (*App).DiffManifest,
+	})
+}
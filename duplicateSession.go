@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// DuplicateSession copies a session to a new name.
+func (app *App) DuplicateSession(inv *Invocation) error {
+	return app.client.DuplicateSession(app.ctx, inv.Args[0], inv.Args[1])
+}
+
+func init() {
+	registerCommand(&Command{
+		Name:    "dup",
+		Aliases: []string{"duplicate"},
+		Summary: "Duplicate a session.",
+		Args: []Arg{
+			{Name: "SRC", Help: "The name of the session to copy.", Complete: (*App).sessionNames},
+			{Name: "DST", Help: "The name of the new session."},
+		},
+		Help: func(w io.Writer) {
+			fmt.Fprintf(w, "Example:\n")
+			fmt.Fprintf(w, "gonzoctl dup session1 session1-backup\n")
+		},
+		Run: (*App).DuplicateSession,
+	})
+}
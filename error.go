@@ -1,24 +1,143 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/gonzoctl/gonzo"
 	"github.com/scgolang/nsm"
 )
 
-// Error represents the data contained in an error response from a non session manager.
-type Error struct {
-	nsmErr  nsm.Error
-	Address string
+// Exit codes for failures that happen in gonzoctl rather than in gonzo.
+const (
+	ExitOK      = 0
+	ExitFailure = 1
+	ExitUsage   = 2
+	ExitTimeout = 3
+	ExitNetwork = 4
+	ExitUnmet   = 5
+)
+
+// localExitCodes describes the exit codes for failures that happen in gonzoctl.
+var localExitCodes = []struct {
+	code int
+	desc string
+}{
+	{ExitOK, "success"},
+	{ExitFailure, "unclassified failure"},
+	{ExitUsage, "usage error, e.g. an unknown command or a wrong number of arguments"},
+	{ExitTimeout, "timeout, the gonzo server did not reply"},
+	{ExitNetwork, "network error, e.g. the gonzo server is not running"},
+	{ExitUnmet, "wait timed out before its conditions held"},
+}
+
+// exitCodes maps the error codes that gonzo can reply with to process exit codes.
+// These codes are stable, new codes are only ever added.
+var exitCodes = map[nsm.Code]int{
+	nsm.ErrGeneral:         10,
+	nsm.ErrIncompatibleAPI: 11,
+	nsm.ErrBlacklisted:     12,
+	nsm.ErrLaunchFailed:    13,
+	nsm.ErrNoSuchFile:      14,
+	nsm.ErrNoSessionOpen:   15,
+	nsm.ErrUnsavedChanges:  16,
+	nsm.ErrNotNow:          17,
+	nsm.ErrBadProject:      18,
+	nsm.ErrCreateFailed:    19,
+}
+
+// UsageError is returned when a command is used incorrectly.
+type UsageError struct {
+	msg string
+}
+
+func (e UsageError) Error() string {
+	return e.msg
 }
 
-func (e Error) Code() nsm.Code {
-	return e.nsmErr.Code()
+// UnmetError is returned when wait times out before its conditions hold.
+type UnmetError struct {
+	msg string
 }
 
-func (e Error) Error() string {
-	return e.nsmErr.Error()
+func (e UnmetError) Error() string {
+	return e.msg
 }
 
-// NewError creates a new error.
-func NewError(nsmErr nsm.Error, addr string) Error {
-	return Error{nsmErr: nsmErr, Address: addr}
+// usageErrorf creates a usage error with printf semantics.
+func usageErrorf(format string, args ...interface{}) error {
+	return UsageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// exitCode returns the process exit code for an error.
+func exitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	cause := errors.Cause(err)
+	if cause == gonzo.ErrTimeout {
+		return ExitTimeout
+	}
+	switch e := cause.(type) {
+	case gonzo.Error:
+		if code, ok := exitCodes[e.Code()]; ok {
+			return code
+		}
+	case UsageError:
+		return ExitUsage
+	case UnmetError:
+		return ExitUnmet
+	case *net.OpError, *net.DNSError, *net.AddrError:
+		return ExitNetwork
+	}
+	return ExitFailure
+}
+
+// printExitCodes prints the documentation of the exit codes.
+func printExitCodes(w io.Writer) {
+	for _, c := range localExitCodes {
+		fmt.Fprintf(w, "%-3d %s\n", c.code, c.desc)
+	}
+	codes := make([]nsm.Code, 0, len(exitCodes))
+	for code := range exitCodes {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		return exitCodes[codes[i]] < exitCodes[codes[j]]
+	})
+	for _, code := range codes {
+		fmt.Fprintf(w, "%-3d gonzo error %d, %s\n", exitCodes[code], code, gonzo.CodeDescription(code))
+	}
+}
+
+// errorReport is how errors are printed when the output format is json.
+type errorReport struct {
+	Error    string   `json:"error"`
+	Code     nsm.Code `json:"code,omitempty"`
+	Address  string   `json:"address,omitempty"`
+	ExitCode int      `json:"exit_code"`
+}
+
+// fatal prints an error and exits with the exit code for that error.
+// If the output format is json the error is printed as a JSON object.
+func fatal(err error, output string) {
+	code := exitCode(err)
+
+	if output != OutputJSON {
+		log.Println(err)
+		os.Exit(code)
+	}
+	report := errorReport{Error: err.Error(), ExitCode: code}
+	if e, ok := errors.Cause(err).(gonzo.Error); ok {
+		report.Code = e.Code()
+		report.Address = e.Address
+	}
+	_ = json.NewEncoder(os.Stderr).Encode(report)
+	os.Exit(code)
 }
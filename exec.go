@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// script runs gonzoctl commands from a script or the shell, one per line,
+// over the app's connection to gonzo.
+type script struct {
+	app  *App
+	name string
+	vars map[string]string
+
+	// interactive scripts are typed in the shell: Ctrl-C only stops the running command.
+	interactive bool
+
+	// errexit stops the script at the first command that fails, like set -e.
+	errexit bool
+
+	// xtrace prints each command before it runs, like set -x.
+	xtrace bool
+}
+
+// newScript creates a script that stops at the first command that fails.
+// name is used in error messages, e.g. the name of the file.
+func newScript(app *App, name string) *script {
+	return &script{
+		app:     app,
+		name:    name,
+		vars:    map[string]string{},
+		errexit: true,
+	}
+}
+
+// scriptError is returned by a script that kept going after commands failed.
+// Its cause is the cause of the last failure, which sets the exit status.
+type scriptError struct {
+	msg   string
+	cause error
+}
+
+func (e scriptError) Error() string {
+	return e.msg
+}
+
+// Cause returns the cause of the last failure.
+func (e scriptError) Cause() error {
+	return e.cause
+}
+
+// run runs the commands read from r.
+// If errexit is off it keeps going after a command fails and returns a scriptError.
+func (s *script) run(r io.Reader) error {
+	var (
+		scanner = bufio.NewScanner(r)
+		failed  = 0
+		lastErr error
+	)
+	for n := 1; scanner.Scan(); n++ {
+		exit, err := s.line(scanner.Text())
+		if err != nil {
+			err = errors.Wrapf(err, "%s:%d", s.name, n)
+			if s.errexit {
+				return err
+			}
+			log.Println(err)
+			failed, lastErr = failed+1, err
+		}
+		if exit {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return errors.Wrap(err, "reading "+s.name)
+	}
+	if lastErr != nil {
+		return scriptError{
+			msg:   fmt.Sprintf("%s: %d commands failed", s.name, failed),
+			cause: errors.Cause(lastErr),
+		}
+	}
+	return nil
+}
+
+// line runs a line of a script.
+// It returns true if the script should stop.
+func (s *script) line(line string) (bool, error) {
+	words, err := splitWords(line, s.lookup)
+	if err != nil {
+		return false, usageErrorf("%s", err)
+	}
+	if len(words) == 0 {
+		return false, nil
+	}
+	if name, value, ok := assignment(words); ok {
+		s.vars[name] = value
+		return false, nil
+	}
+	switch words[0] {
+	case "exit":
+		return true, nil
+	case "set":
+		return false, s.set(words[1:])
+	case "shell":
+		return false, usageErrorf("already in a shell")
+	}
+	if s.xtrace {
+		fmt.Fprintf(os.Stderr, "+ %s\n", strings.Join(words, " "))
+	}
+	// Global flags like -o only apply to the command on this line.
+	config, words, err := s.app.Config.withGlobalFlags(words)
+	if err != nil {
+		return false, err
+	}
+	app := *s.app
+	app.Config = config
+
+	if s.interactive {
+		return false, app.shellCommand(words[0], words[1:])
+	}
+	return false, app.runCommand(words)
+}
+
+// set changes the options of the script, or prints its variables if there are no arguments.
+func (s *script) set(args []string) error {
+	if len(args) == 0 {
+		names := make([]string, 0, len(s.vars))
+		for name := range s.vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("%s=%s\n", name, s.vars[name])
+		}
+		return nil
+	}
+	for _, arg := range args {
+		if len(arg) < 2 || (arg[0] != '-' && arg[0] != '+') {
+			return usageErrorf("expected set -e, set +e, set -x or set +x, got %s", arg)
+		}
+		on := arg[0] == '-'
+		for _, opt := range arg[1:] {
+			switch opt {
+			case 'e':
+				s.errexit = on
+			case 'x':
+				s.xtrace = on
+			default:
+				return usageErrorf("unrecognized option for set: %c", opt)
+			}
+		}
+	}
+	return nil
+}
+
+// lookup returns the value of a variable of the script, or of an environment variable.
+func (s *script) lookup(name string) (string, error) {
+	if value, ok := s.vars[name]; ok {
+		return value, nil
+	}
+	if value, ok := os.LookupEnv(name); ok {
+		return value, nil
+	}
+	return "", errors.Errorf("undefined variable: %s", name)
+}
+
+// assignment returns the name and value if words is a single NAME=VALUE word.
+func assignment(words []string) (string, string, bool) {
+	if len(words) != 1 {
+		return "", "", false
+	}
+	i := strings.Index(words[0], "=")
+	if i <= 0 || !isVariableName(words[0][:i]) {
+		return "", "", false
+	}
+	return words[0][:i], words[0][i+1:], true
+}
+
+// isVariableName returns true if s can be the name of a variable.
+func isVariableName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if !isVariableRune(r, i == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// isVariableRune returns true if r can be in the name of a variable.
+// Names start with a letter or an underscore.
+func isVariableRune(r rune, first bool) bool {
+	switch {
+	case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		return true
+	case r >= '0' && r <= '9':
+		return !first
+	}
+	return false
+}
+
+// Exec runs commands from a file or stdin over a single connection to gonzo.
+func (app *App) Exec(inv *Invocation) error {
+	var (
+		file = inv.String("f")
+		s    = newScript(app, file)
+		r    io.Reader
+	)
+	for _, arg := range inv.Args {
+		name, value, ok := assignment([]string{arg})
+		if !ok {
+			return usageErrorf("expected NAME=VALUE, got %s", arg)
+		}
+		s.vars[name] = value
+	}
+	if file == "-" {
+		s.name, r = "stdin", os.Stdin
+	} else {
+		f, err := os.Open(file)
+		if err != nil {
+			return errors.Wrap(err, "opening script")
+		}
+		defer func() { _ = f.Close() }()
+		r = f
+	}
+	return s.run(r)
+}
+
+func init() {
+	registerCommand(&Command{
+		Name:    "exec",
+		Summary: "Run commands from a script over a single connection to gonzo.",
+		Args: []Arg{
+			{Name: "NAME=VALUE", Help: "Set a variable of the script.", Optional: true, Variadic: true},
+		},
+		Flags: []Flag{
+			{Name: "f", Value: "FILE", Default: "-", Help: "Read commands from FILE, or from stdin if FILE is - (default)."},
+		},
+		Help: func(w io.Writer) {
+			fmt.Fprintf(w, "Scripts have one gonzoctl command per line, without gonzoctl, e.g. add synth zynaddsubfx.\n")
+			fmt.Fprintf(w, "Words are split and quoted like in a shell. The script stops at the first command that fails\n")
+			fmt.Fprintf(w, "and gonzoctl exits with the exit status of that command. The error says which line failed.\n")
+			fmt.Fprintf(w, "\n")
+			fmt.Fprintf(w, "Global options after a command, e.g. ls -o json, only apply to that line. Options that\n")
+			fmt.Fprintf(w, "change the connection, like -host and -timeout, must be given before exec.\n")
+			fmt.Fprintf(w, "\n")
+			fmt.Fprintf(w, "Script syntax:\n")
+			fmt.Fprintf(w, "# comment         Everything from a word that starts with # to the end of the line is ignored.\n")
+			fmt.Fprintf(w, "NAME=VALUE        Set a variable.\n")
+			fmt.Fprintf(w, "$NAME, ${NAME}    The value of a variable, or of an environment variable.\n")
+			fmt.Fprintf(w, "                  Variables are not expanded in single quotes. Undefined variables are an error.\n")
+			fmt.Fprintf(w, "set -e, set +e    Stop at the first command that fails (default), or keep going.\n")
+			fmt.Fprintf(w, "                  With set +e the exit status is that of the last command that failed.\n")
+			fmt.Fprintf(w, "set -x, set +x    Print each command to stderr before it runs, or stop printing them.\n")
+			fmt.Fprintf(w, "set               Print the variables of the script.\n")
+			fmt.Fprintf(w, "exit              Stop the script.\n")
+			fmt.Fprintf(w, "\n")
+			fmt.Fprintf(w, "Example:\n")
+			fmt.Fprintf(w, "# show.gz\n")
+			fmt.Fprintf(w, "new $SHOW\n")
+			fmt.Fprintf(w, "add synth zynaddsubfx\n")
+			fmt.Fprintf(w, "add drums hydrogen\n")
+			fmt.Fprintf(w, "save\n")
+			fmt.Fprintf(w, "\n")
+			fmt.Fprintf(w, "gonzoctl exec -f show.gz SHOW=friday\n")
+		},
+		Run: (*App).Exec,
+	})
+}
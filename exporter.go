@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/gonzoctl/gonzo"
+)
+
+// Exporter settings.
+const (
+	// DefaultExporterListen is the default address of the metrics exporter.
+	DefaultExporterListen = ":9561"
+
+	// DefaultExporterInterval is how often the exporter polls gonzo by default.
+	DefaultExporterInterval = 15 * time.Second
+)
+
+// clientKey identifies a client in a session.
+type clientKey struct {
+	session string
+	client  string
+}
+
+// exporter keeps the metrics that the exporter serves.
+type exporter struct {
+	mu sync.Mutex
+
+	up       bool
+	rtt      time.Duration
+	sessions int
+	session  string
+	clients  []gonzo.ClientInfo
+
+	// pids has the last PID that was seen for each client, which is used to count restarts.
+	pids     map[clientKey]int32
+	restarts map[clientKey]int
+	errors   map[string]int
+}
+
+// Exporter serves metrics about the gonzo server for Prometheus.
+func (app *App) Exporter(inv *Invocation) error {
+	interval := inv.Duration("interval")
+	if interval <= 0 {
+		return usageErrorf("expected a positive interval, got %s", interval)
+	}
+	app.keepServing()
+
+	e := &exporter{
+		pids:     map[clientKey]int32{},
+		restarts: map[clientKey]int{},
+		errors:   map[string]int{},
+	}
+	go app.pollMetrics(e, interval)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = io.WriteString(w, "gonzo exporter, metrics are at /metrics\n")
+	})
+	return app.listenAndServe(inv.String("listen"), mux)
+}
+
+// pollMetrics polls gonzo until the app is canceled.
+func (app *App) pollMetrics(e *exporter, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		app.pollMetricsOnce(e)
+
+		select {
+		case <-app.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pollMetricsOnce pings gonzo and gets its sessions and clients.
+func (app *App) pollMetricsOnce(e *exporter) {
+	var (
+		sessions []gonzo.Session
+		current  = -1
+		clients  []gonzo.ClientInfo
+	)
+	rtt, pingErr := app.client.Ping(app.ctx)
+	err := pingErr
+	if err == nil {
+		sessions, current, err = app.client.Sessions(app.ctx)
+	}
+	if err == nil && current >= 0 {
+		clients, err = app.client.Clients(app.ctx)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err != nil {
+		app.debugf("polling gonzo: %s", err)
+		e.errors[errorCodeLabel(err)]++
+	}
+	e.up = pingErr == nil
+	if !e.up {
+		e.rtt, e.sessions, e.session, e.clients = 0, 0, "", nil
+		return
+	}
+	e.rtt, e.sessions = rtt, len(sessions)
+
+	if err != nil {
+		return
+	}
+	e.session, e.clients = "", clients
+	if current >= 0 {
+		e.session = sessions[current].Name
+	}
+	for _, client := range clients {
+		if client.PID == 0 {
+			continue
+		}
+		key := clientKey{session: e.session, client: client.Name}
+		if pid, ok := e.pids[key]; ok && pid != client.PID {
+			e.restarts[key]++
+		}
+		e.pids[key] = client.PID
+	}
+}
+
+// errorCodeLabel returns the value of the code label of gonzo_request_errors_total for an error.
+// Errors from gonzo are labeled with their error code.
+func errorCodeLabel(err error) string {
+	cause := errors.Cause(err)
+	if cause == gonzo.ErrTimeout {
+		return "timeout"
+	}
+	switch e := cause.(type) {
+	case gonzo.Error:
+		return strconv.Itoa(int(e.Code()))
+	case *net.OpError, *net.DNSError, *net.AddrError:
+		return "network"
+	}
+	return "other"
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (e *exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+
+	e.mu.Lock()
+	e.writeMetrics(&buf)
+	e.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// writeMetrics writes the metrics.
+func (e *exporter) writeMetrics(w io.Writer) {
+	writeMetricHeader(w, "gonzo_up", "gauge", "Whether the gonzo server replied to the last ping.")
+	fmt.Fprintf(w, "gonzo_up %d\n", boolValue(e.up))
+
+	writeMetricHeader(w, "gonzo_ping_rtt_seconds", "gauge", "Round trip time of the last ping.")
+	fmt.Fprintf(w, "gonzo_ping_rtt_seconds %g\n", e.rtt.Seconds())
+
+	writeMetricHeader(w, "gonzo_sessions", "gauge", "Number of sessions.")
+	fmt.Fprintf(w, "gonzo_sessions %d\n", e.sessions)
+
+	writeMetricHeader(w, "gonzo_clients", "gauge", "Clients of the current session, 1 if the client is running and 0 if it is not.")
+	for _, client := range e.clients {
+		fmt.Fprintf(w, "gonzo_clients%s %d\n", clientLabels(e.session, client.Name), boolValue(client.Running()))
+	}
+	writeMetricHeader(w, "gonzo_client_dirty", "gauge", "Whether a client has unsaved changes.")
+	for _, client := range e.clients {
+		fmt.Fprintf(w, "gonzo_client_dirty%s %d\n", clientLabels(e.session, client.Name), boolValue(client.Dirty()))
+	}
+	writeMetricHeader(w, "gonzo_client_restarts_total", "counter", "Number of times a client was seen with a new PID.")
+	keys := make([]clientKey, 0, len(e.restarts))
+	for key := range e.restarts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].session != keys[j].session {
+			return keys[i].session < keys[j].session
+		}
+		return keys[i].client < keys[j].client
+	})
+	for _, key := range keys {
+		fmt.Fprintf(w, "gonzo_client_restarts_total%s %d\n", clientLabels(key.session, key.client), e.restarts[key])
+	}
+	writeMetricHeader(w, "gonzo_request_errors_total", "counter", "Number of failed requests to gonzo by error code.")
+	codes := make([]string, 0, len(e.errors))
+	for code := range e.errors {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		fmt.Fprintf(w, "gonzo_request_errors_total{code=\"%s\"} %d\n", escapeLabel(code), e.errors[code])
+	}
+}
+
+// writeMetricHeader writes the HELP and TYPE lines of a metric.
+func writeMetricHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+}
+
+// clientLabels returns the labels of a client metric.
+func clientLabels(session, client string) string {
+	return fmt.Sprintf("{session=\"%s\",client=\"%s\"}", escapeLabel(session), escapeLabel(client))
+}
+
+// escapeLabel escapes a label value.
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// boolValue returns 1 for true and 0 for false.
+func boolValue(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func init() {
+	registerCommand(&Command{
+		Name:    "exporter",
+		Summary: "Serve metrics about a gonzo server for Prometheus.",
+		Flags: []Flag{
+			{Name: "listen", Value: "ADDRESS", Default: DefaultExporterListen, Help: fmt.Sprintf("Address to listen on (default %s).", DefaultExporterListen)},
+			{Name: "interval", Value: "DURATION", Default: DefaultExporterInterval, Help: fmt.Sprintf("How often to poll gonzo (default %s).", DefaultExporterInterval)},
+		},
+		Help: func(w io.Writer) {
+			fmt.Fprintf(w, "Metrics are served at /metrics:\n")
+			fmt.Fprintf(w, "gonzo_up                         1 if gonzo replied to the last ping, 0 if it did not.\n")
+			fmt.Fprintf(w, "gonzo_ping_rtt_seconds           Round trip time of the last ping.\n")
+			fmt.Fprintf(w, "gonzo_sessions                   Number of sessions.\n")
+			fmt.Fprintf(w, "gonzo_clients{session,client}    Clients of the current session, 1 if running.\n")
+			fmt.Fprintf(w, "gonzo_client_dirty               1 if a client has unsaved changes.\n")
+			fmt.Fprintf(w, "gonzo_client_restarts_total      Number of times a client was seen with a new PID.\n")
+			fmt.Fprintf(w, "gonzo_request_errors_total{code} Failed requests by gonzo error code, or timeout or network.\n")
+			fmt.Fprintf(w, "\n")
+			fmt.Fprintf(w, "Alert on gonzo_up == 0 to find out when gonzo goes down.\n")
+		},
+		Run: (*App).Exporter,
+	})
+}
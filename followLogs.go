@@ -0,0 +1,186 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// followInterval is how often the logs of a client are polled in follow mode.
+const followInterval = time.Second
+
+// logTimestampLayouts are the timestamp layouts that are recognized at the start of a log line.
+var logTimestampLayouts = []string{
+	"2006/01/02 15:04:05.000000",
+	"2006/01/02 15:04:05",
+	"2006-01-02 15:04:05",
+	time.RFC3339Nano,
+}
+
+// logBacklog limits the log lines that are printed before following the logs.
+type logBacklog struct {
+	numLines int
+	since    time.Duration
+}
+
+// filter returns the lines that are within the limits of the backlog.
+func (b logBacklog) filter(lines []string, now time.Time) []string {
+	if b.since > 0 {
+		var (
+			cutoff   = now.Add(-b.since)
+			lastTime time.Time
+			kept     = []string{}
+		)
+		for _, line := range lines {
+			if t, ok := lineTime(line); ok {
+				lastTime = t
+			}
+			if !lastTime.Before(cutoff) {
+				kept = append(kept, line)
+			}
+		}
+		lines = kept
+	}
+	if b.numLines >= 0 && b.numLines < len(lines) {
+		lines = lines[len(lines)-b.numLines:]
+	}
+	return lines
+}
+
+// logSource is one output stream of one client.
+type logSource struct {
+	client string
+	stream string
+}
+
+// logPoller polls the logs of clients and remembers the lines it has seen,
+// so that each line is only returned once.
+type logPoller struct {
+	app     *App
+	backlog logBacklog
+	seen    map[logSource][]string
+}
+
+// newLogPoller creates a new log poller.
+func newLogPoller(app *App, backlog logBacklog) *logPoller {
+	return &logPoller{
+		app:     app,
+		backlog: backlog,
+		seen:    map[logSource][]string{},
+	}
+}
+
+// poll returns the lines of a log source that have not been returned before.
+// The first time a source is polled only the lines within the backlog are returned.
+func (p *logPoller) poll(src logSource) ([]string, error) {
+	logs, err := p.app.clientLogs(src.client, src.stream)
+	if err != nil {
+		return nil, err
+	}
+	prev, seen := p.seen[src]
+	p.seen[src] = logs.Lines
+
+	lines := newLines(prev, logs.Lines)
+	if !seen {
+		lines = p.backlog.filter(lines, time.Now())
+	}
+	return lines, nil
+}
+
+// follow calls f every followInterval until the app is canceled.
+// Errors returned by f, e.g. because gonzo is restarting, are reported once
+// and following continues.
+func (app *App) follow(f func() error) error {
+	app.keepServing()
+
+	var (
+		ticker  = time.NewTicker(followInterval)
+		lastErr string
+	)
+	defer ticker.Stop()
+
+	for {
+		if err := f(); err != nil {
+			if err.Error() != lastErr {
+				log.Printf("waiting for logs: %s", err)
+				lastErr = err.Error()
+			}
+		} else if lastErr != "" {
+			app.debug("resumed following logs")
+			lastErr = ""
+		}
+		select {
+		case <-app.ctx.Done():
+			return app.ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// followLogs prints the logs of a client and then polls for new lines until the app is canceled.
+func (app *App) followLogs(clientName, stream string, backlog logBacklog) error {
+	var (
+		poller = newLogPoller(app, backlog)
+		src    = logSource{client: clientName, stream: stream}
+	)
+	return app.follow(func() error {
+		lines, err := poller.poll(src)
+		if err != nil || len(lines) == 0 {
+			return err
+		}
+		return errors.Wrap(app.print(Logs{
+			Client: clientName,
+			Stream: stream,
+			Lines:  lines,
+		}), "printing client logs")
+	})
+}
+
+// newLines returns the lines of cur that are not in prev.
+// The logs that gonzo returns are a window over the output of a client,
+// so the oldest lines in prev may have been dropped from cur.
+// newLines finds the longest suffix of prev that is a prefix of cur.
+func newLines(prev, cur []string) []string {
+	n := len(prev)
+	if len(cur) < n {
+		n = len(cur)
+	}
+	for k := n; k > 0; k-- {
+		if equalLines(prev[len(prev)-k:], cur[:k]) {
+			return cur[k:]
+		}
+	}
+	return cur
+}
+
+// equalLines returns true if a and b contain the same lines.
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// lineTime parses the timestamp at the start of a log line.
+func lineTime(line string) (time.Time, bool) {
+	for _, layout := range logTimestampLayouts {
+		if len(line) < len(layout) {
+			continue
+		}
+		prefix := line[:len(layout)]
+		if layout == time.RFC3339Nano {
+			prefix = strings.SplitN(line, " ", 2)[0]
+		}
+		if t, err := time.ParseInLocation(layout, prefix, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/gonzoctl/gonzo"
+	"github.com/scgolang/nsm"
+)
+
+// GetSession prints the manifest of the current session.
+func (app *App) GetSession(inv *Invocation) error {
+	sessions, current, err := app.client.Sessions(app.ctx)
+	if err != nil {
+		return err
+	}
+	if current < 0 {
+		return gonzo.NewError(nsm.NewError(nsm.ErrNoSessionOpen, ""), nsm.AddressServerSessions)
+	}
+	clients, err := app.client.Clients(app.ctx)
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(app.print(sessionManifest(sessions[current].Name, clients)), "printing manifest")
+}
+
+func init() {
+	registerCommand(&Command{
+		Name:    "get",
+		Summary: "Export resources as manifests.",
+		Subcommands: []*Command{
+			{
+				Name:    "session",
+				Summary: "Print the manifest of the current session.",
+				Help: func(w io.Writer) {
+					fmt.Fprintf(w, "The manifest is written as YAML, or as JSON with -o json. It can be applied with\n")
+					fmt.Fprintf(w, "gonzoctl apply. The GUI of clients with the %s capability is recorded as it is now.\n", nsm.CapGUI)
+					fmt.Fprintf(w, "\n")
+					fmt.Fprintf(w, "Example:\n")
+					fmt.Fprintf(w, "gonzoctl get session -o yaml > show.yaml\n")
+				},
+				Run: (*App).GetSession,
+			},
+		},
+	})
+}
@@ -0,0 +1,290 @@
+// Package gonzo is a client for the control API of a gonzo server.
+// gonzo is a non session manager that speaks the nsm server API over OSC,
+// see http://non.tuxfamily.org/nsm/API.html
+package gonzo
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/nsm"
+	"github.com/scgolang/osc"
+)
+
+// DefaultPort is the port that gonzo listens on by default.
+const DefaultPort = 56070
+
+// eventBufferSize is the number of events that gonzo can send
+// before we start dropping them.
+const eventBufferSize = 64
+
+// Client talks to a gonzo server.
+// A Client is safe for concurrent use.
+type Client struct {
+	// Timeout is how long a request waits for gonzo to reply,
+	// including retries.
+	Timeout time.Duration
+
+	// Retries is the number of times a request that only reads gonzo's state
+	// is resent when gonzo does not reply. Requests that change gonzo's state
+	// are only resent when they can not be sent.
+	Retries int
+
+	// Logf, if not nil, is used to log what the client is doing.
+	Logf func(format string, args ...interface{})
+
+	conn osc.Conn
+
+	// reconnect is set to 1 by KeepServing.
+	reconnect int32
+
+	done    chan struct{}
+	errMu   sync.Mutex
+	err     error
+	events  chan Event
+	pending *pendingRequests
+	pongs   chan osc.Message
+
+	// listeners get a copy of every event, see Listen.
+	listenersMu sync.Mutex
+	listeners   map[chan Event]struct{}
+}
+
+// Dial creates a client for the gonzo server at host and port.
+// The client stops serving when ctx is canceled.
+func Dial(ctx context.Context, host string, port int, timeout time.Duration) (*Client, error) {
+	raddr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not resolve remote udp address")
+	}
+	laddr, err := net.ResolveUDPAddr("udp", "0.0.0.0:0")
+	if err != nil {
+		return nil, errors.Wrap(err, "could not resolve local udp address")
+	}
+	conn, err := osc.DialUDPContext(ctx, "udp", laddr, raddr)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not listen on udp")
+	}
+	return NewClient(conn, timeout), nil
+}
+
+// NewClient creates a client that talks to gonzo over an existing connection.
+// The connection must send to the gonzo server, and the client serves it
+// until it is closed.
+func NewClient(conn osc.Conn, timeout time.Duration) *Client {
+	c := &Client{
+		Timeout: timeout,
+		Retries: DefaultRetries,
+
+		conn:      conn,
+		done:      make(chan struct{}),
+		events:    make(chan Event, eventBufferSize),
+		pending:   newPendingRequests(),
+		pongs:     make(chan osc.Message, 1),
+		listeners: map[chan Event]struct{}{},
+	}
+	go c.serve()
+	return c
+}
+
+// Close closes the connection to gonzo.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Conn returns the connection to gonzo.
+func (c *Client) Conn() osc.Conn {
+	return c.conn
+}
+
+// Done returns a channel that is closed when the client stops serving its connection,
+// e.g. because the connection was closed or gonzo is not running.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns the reason why the client stopped serving its connection.
+// It returns nil while the client is serving.
+func (c *Client) Err() error {
+	c.errMu.Lock()
+	defer c.errMu.Unlock()
+	return c.err
+}
+
+// Events returns the events that gonzo sends.
+// Use Subscribe to receive events for all clients.
+// Events are dropped when nobody reads them.
+func (c *Client) Events() <-chan Event {
+	return c.events
+}
+
+// Listen returns a channel that gets a copy of every event that gonzo sends,
+// independently of Events and of other listeners, and a func that stops listening.
+// Events are dropped when the listener does not keep up.
+func (c *Client) Listen() (<-chan Event, func()) {
+	ch := make(chan Event, eventBufferSize)
+
+	c.listenersMu.Lock()
+	c.listeners[ch] = struct{}{}
+	c.listenersMu.Unlock()
+
+	return ch, func() {
+		c.listenersMu.Lock()
+		delete(c.listeners, ch)
+		c.listenersMu.Unlock()
+	}
+}
+
+// KeepServing tells the client to keep serving its connection when gonzo goes away,
+// so that it can carry on when gonzo restarts.
+func (c *Client) KeepServing() {
+	atomic.StoreInt32(&c.reconnect, 1)
+}
+
+// serve serves the connection to gonzo until it fails.
+// If KeepServing has been called then serve keeps going
+// when the gonzo server is unreachable.
+func (c *Client) serve() {
+	var err error
+	for {
+		err = c.conn.Serve(c.dispatcher())
+		if err == nil {
+			break
+		}
+		c.logf("serve error %s", err)
+
+		if atomic.LoadInt32(&c.reconnect) == 0 || !isConnRefused(err) {
+			break
+		}
+	}
+	c.errMu.Lock()
+	c.err = err
+	if c.err == nil {
+		c.err = errors.New("connection closed")
+	}
+	c.errMu.Unlock()
+	close(c.done)
+}
+
+// dispatcher returns an osc dispatcher that handles replies from gonzo.
+func (c *Client) dispatcher() osc.Dispatcher {
+	d := osc.Dispatcher{
+		nsm.AddressError: c.handleError,
+		AddressPong:      c.handlePong,
+		nsm.AddressReply: c.handleReply,
+	}
+	for addr := range eventTypes {
+		d[addr] = c.handleEvent
+	}
+	return d
+}
+
+// handleError handles error replies from gonzo.
+// Errors for requests that are no longer pending are dropped.
+func (c *Client) handleError(msg osc.Message) error {
+	if len(msg.Arguments) != 3 {
+		return errors.New("expected 3 arguments for error message")
+	}
+	address, err := msg.Arguments[0].ReadString()
+	if err != nil {
+		return errors.Wrap(err, "reading address in error message")
+	}
+	code, err := msg.Arguments[1].ReadInt32()
+	if err != nil {
+		return errors.Wrap(err, "reading code in error message")
+	}
+	errmsg, err := msg.Arguments[2].ReadString()
+	if err != nil {
+		return errors.Wrap(err, "reading errmsg in error message")
+	}
+	c.logf("received error: address=%s code=%d message=%s", address, code, errmsg)
+
+	w := c.pending.take(address)
+	if w == nil {
+		c.logf("dropping error for %s: no pending request", address)
+		return nil
+	}
+	w.errors <- NewError(nsm.NewError(nsm.Code(code), errmsg), address)
+	return nil
+}
+
+// handleEvent handles messages that gonzo forwards from its clients.
+// Events that can not be parsed or that nobody is waiting for are dropped.
+func (c *Client) handleEvent(msg osc.Message) error {
+	ev, err := parseEvent(msg)
+	if err != nil {
+		c.logf("could not parse event: %s", err)
+		return nil
+	}
+	select {
+	case c.events <- ev:
+	default:
+		c.logf("dropping event %s", msg.Address)
+	}
+	c.listenersMu.Lock()
+	for ch := range c.listeners {
+		select {
+		case ch <- ev:
+		default:
+			c.logf("dropping event %s for a listener", msg.Address)
+		}
+	}
+	c.listenersMu.Unlock()
+
+	return nil
+}
+
+// handlePong handles ping responses from gonzo.
+func (c *Client) handlePong(msg osc.Message) error {
+	select {
+	case c.pongs <- msg:
+	default:
+		c.logf("dropping pong")
+	}
+	return nil
+}
+
+// handleReply handles replies from gonzo.
+// Replies to requests that are no longer pending are dropped.
+func (c *Client) handleReply(msg osc.Message) error {
+	if len(msg.Arguments) == 0 {
+		return errors.New("expected address in reply")
+	}
+	addr, err := msg.Arguments[0].ReadString()
+	if err != nil {
+		return errors.Wrap(err, "reading first argument of reply")
+	}
+	c.logf("received reply for %s", addr)
+
+	w := c.pending.take(addr)
+	if w == nil {
+		c.logf("dropping reply for %s: no pending request", addr)
+		return nil
+	}
+	w.replies <- msg
+	return nil
+}
+
+// logf logs a message if the client has a logger.
+func (c *Client) logf(format string, args ...interface{}) {
+	if c.Logf != nil {
+		c.Logf(format, args...)
+	}
+}
+
+// isConnRefused returns true if err happened because nothing is listening on the remote address.
+func isConnRefused(err error) bool {
+	opErr, ok := errors.Cause(err).(*net.OpError)
+	if !ok {
+		return false
+	}
+	sysErr, ok := opErr.Err.(*os.SyscallError)
+	return ok && sysErr.Err == syscall.ECONNREFUSED
+}
@@ -0,0 +1,293 @@
+package gonzo
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/nsm"
+	"github.com/scgolang/osc"
+)
+
+// Client status flags.
+const (
+	StatusDirty      = "dirty"
+	StatusGUIVisible = "gui-visible"
+	StatusRunning    = "running"
+)
+
+// Log streams of a client.
+const (
+	StreamStderr = "stderr"
+	StreamStdout = "stdout"
+)
+
+// streams maps the names of log streams to their values in the gonzo OSC API.
+var streams = map[string]int32{StreamStderr: 2, StreamStdout: 1}
+
+// ClientInfo describes a client that is managed by a gonzo server.
+type ClientInfo struct {
+	Name         string           `json:"name"`
+	Executable   string           `json:"executable"`
+	ID           string           `json:"id"`
+	PID          int32            `json:"pid"`
+	Capabilities nsm.Capabilities `json:"capabilities"`
+	Status       []string         `json:"status"`
+}
+
+// Dirty returns true if the client has unsaved changes.
+func (c ClientInfo) Dirty() bool {
+	return c.hasStatus(StatusDirty)
+}
+
+// GUIVisible returns true if the client's optional GUI is showing.
+func (c ClientInfo) GUIVisible() bool {
+	return c.hasStatus(StatusGUIVisible)
+}
+
+// HasCapability returns true if the client has the provided capability.
+func (c ClientInfo) HasCapability(capability nsm.Capability) bool {
+	for _, cap := range c.Capabilities {
+		if cap == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// Running returns true if the client's process is running.
+func (c ClientInfo) Running() bool {
+	return c.hasStatus(StatusRunning)
+}
+
+// hasStatus returns true if the client's status contains flag.
+func (c ClientInfo) hasStatus(flag string) bool {
+	for _, f := range c.Status {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// FindClient returns the client with the provided name.
+func FindClient(clients []ClientInfo, name string) (ClientInfo, bool) {
+	for _, client := range clients {
+		if client.Name == name {
+			return client, true
+		}
+	}
+	return ClientInfo{}, false
+}
+
+// Clients gets the clients in the current session.
+func (c *Client) Clients(ctx context.Context) ([]ClientInfo, error) {
+	reply, err := c.requestRetry(ctx, osc.Message{
+		Address: nsm.AddressServerClients,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "listing clients")
+	}
+	clients, err := parseClients(reply)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing clients")
+	}
+	return clients, nil
+}
+
+// Add adds a client to the current session.
+// executable is the program that gonzo launches for the client.
+func (c *Client) Add(ctx context.Context, name, executable string) error {
+	added := func() (bool, error) {
+		clients, err := c.Clients(ctx)
+		if err != nil {
+			return false, err
+		}
+		_, ok := FindClient(clients, name)
+		return ok, nil
+	}
+	if _, err := c.requestVerified(ctx, osc.Message{
+		Address: nsm.AddressServerAdd,
+		Arguments: osc.Arguments{
+			osc.String(name),
+			osc.String(executable),
+		},
+	}, added); err != nil {
+		return errors.Wrap(err, "adding client "+name)
+	}
+	return nil
+}
+
+// Logs gets the logs of a client.
+// stream must be either StreamStderr or StreamStdout.
+func (c *Client) Logs(ctx context.Context, name, stream string) ([]string, error) {
+	value, ok := streams[stream]
+	if !ok {
+		return nil, errors.Errorf("expected log stream to be either %s or %s, got %s", StreamStderr, StreamStdout, stream)
+	}
+	reply, err := c.requestRetry(ctx, osc.Message{
+		Address: nsm.AddressClientLogs,
+		Arguments: osc.Arguments{
+			osc.String(name),
+			osc.Int(value),
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "getting client logs")
+	}
+	lines, err := parseClientLogs(name, reply)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing client logs")
+	}
+	return lines, nil
+}
+
+// SetGUI shows or hides the optional GUI of a client and waits for the client to confirm.
+// The confirmation is read with Listen, so it is not taken away from readers of Events.
+func (c *Client) SetGUI(ctx context.Context, name string, show bool) error {
+	var (
+		addr    = nsm.AddressClientHideOptionalGUI
+		confirm = EventGUIHidden
+	)
+	if show {
+		addr, confirm = nsm.AddressClientShowOptionalGUI, EventGUIShowing
+	}
+	// Listen before sending, so that a quick confirmation is not missed.
+	events, stop := c.Listen()
+	defer stop()
+
+	if _, err := c.requestRetry(ctx, osc.Message{
+		Address: addr,
+		Arguments: osc.Arguments{
+			osc.String(name),
+		},
+	}); err != nil {
+		return errors.Wrap(err, "sending "+addr+" to "+name)
+	}
+	timeout := time.After(c.Timeout)
+
+	c.logf("waiting for %s from %s", confirm, name)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout:
+			return errors.Errorf("timeout waiting for %s from %s", confirm, name)
+		case ev := <-events:
+			if ev.Type == confirm && ev.Client == name {
+				return nil
+			}
+		}
+	}
+}
+
+// parseClients parses clients from an OSC reply to /nsm/server/clients
+// Each client in the reply has six fields:
+// name, executable, client ID, PID, capabilities and status.
+// The status is a list of flags encoded the same way as capabilities.
+func parseClients(msg osc.Message) ([]ClientInfo, error) {
+	const numClientFields = 6
+
+	if len(msg.Arguments) < 2 {
+		return nil, errors.New("expected two arguments")
+	}
+	addr, err := msg.Arguments[0].ReadString()
+	if err != nil {
+		return nil, errors.Wrap(err, "reading reply address from osc message")
+	}
+	if expected, got := nsm.AddressServerClients, addr; expected != got {
+		return nil, errors.Errorf("expected reply to %s, got %s", expected, got)
+	}
+	numClients, err := msg.Arguments[1].ReadInt32()
+	if err != nil {
+		return nil, errors.Wrap(err, "reading number of clients from osc message")
+	}
+	if expected, got := (numClients*numClientFields)+2, int32(len(msg.Arguments)); expected != got {
+		return nil, errors.Errorf("expected %d arguments, got %d", expected, got)
+	}
+	clients := make([]ClientInfo, numClients)
+
+	for i := int32(0); i < numClients; i++ {
+		var (
+			args   = msg.Arguments[(i*numClientFields)+2:]
+			client = &clients[i]
+		)
+		if client.Name, err = args[0].ReadString(); err != nil {
+			return nil, errors.Wrap(err, "reading client name from osc message")
+		}
+		if client.Executable, err = args[1].ReadString(); err != nil {
+			return nil, errors.Wrap(err, "reading client executable from osc message")
+		}
+		if client.ID, err = args[2].ReadString(); err != nil {
+			return nil, errors.Wrap(err, "reading client ID from osc message")
+		}
+		if client.PID, err = args[3].ReadInt32(); err != nil {
+			return nil, errors.Wrap(err, "reading client PID from osc message")
+		}
+		caps, err := args[4].ReadString()
+		if err != nil {
+			return nil, errors.Wrap(err, "reading client capabilities from osc message")
+		}
+		client.Capabilities = parseFlags(caps)
+
+		status, err := args[5].ReadString()
+		if err != nil {
+			return nil, errors.Wrap(err, "reading client status from osc message")
+		}
+		client.Status = []string{}
+		for _, flag := range parseFlags(status) {
+			client.Status = append(client.Status, string(flag))
+		}
+	}
+	return clients, nil
+}
+
+// parseFlags parses a list of flags that are encoded like nsm capabilities.
+// Unlike nsm.ParseCapabilities it returns an empty list for an empty string.
+func parseFlags(s string) nsm.Capabilities {
+	if strings.Trim(s, nsm.CapSep) == "" {
+		return nsm.Capabilities{}
+	}
+	return nsm.ParseCapabilities(s)
+}
+
+// parseClientLogs parses log messages for a client from an OSC message.
+func parseClientLogs(expectedClientName string, msg osc.Message) ([]string, error) {
+	const minimumNumArgs = 3
+
+	if minimum, got := minimumNumArgs, len(msg.Arguments); got < minimum {
+		return nil, errors.Errorf("expected at least %d arguments, got %d", minimum, got)
+	}
+	addr, err := msg.Arguments[0].ReadString()
+	if err != nil {
+		return nil, errors.Wrap(err, "reading reply address")
+	}
+	if expected, got := nsm.AddressClientLogs, addr; expected != got {
+		return nil, errors.Errorf("expected %s, got %s", expected, got)
+	}
+	clientName, err := msg.Arguments[1].ReadString()
+	if err != nil {
+		return nil, errors.Wrap(err, "reading client name")
+	}
+	if clientName != expectedClientName {
+		return nil, errors.Errorf("expected client name %s, got %s", expectedClientName, clientName)
+	}
+	numLines, err := msg.Arguments[2].ReadInt32()
+	if err != nil {
+		return nil, errors.Wrap(err, "reading num log lines")
+	}
+	if expected, got := numLines, int32(len(msg.Arguments)-minimumNumArgs); expected != got {
+		return nil, errors.Errorf("expected %d log lines, got %d", expected, got)
+	}
+	lines := make([]string, numLines)
+	for i := int32(0); i < numLines; i++ {
+		line, err := msg.Arguments[i+minimumNumArgs].ReadString()
+		if err != nil {
+			return nil, errors.Wrap(err, "reading log line")
+		}
+		lines[i] = line
+	}
+	return lines, nil
+}
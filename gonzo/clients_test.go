@@ -0,0 +1,286 @@
+package gonzo_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/gonzoctl/gonzo"
+	"github.com/scgolang/gonzoctl/gonzotest"
+	"github.com/scgolang/nsm"
+	"github.com/scgolang/osc"
+)
+
+// testTimeout is the timeout of the clients in the tests.
+// With the default retries each attempt of a request that is safe to repeat waits for a quarter of it.
+const testTimeout = time.Second
+
+// newTestClient starts a fake gonzo server and connects a client to it.
+func newTestClient(t *testing.T) (*gonzotest.Server, *gonzo.Client) {
+	t.Helper()
+
+	s, err := gonzotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c, err := gonzo.Dial(ctx, s.Host(), s.Port(), testTimeout)
+	if err != nil {
+		cancel()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = c.Close()
+		cancel()
+		_ = s.Close()
+	})
+	return s, c
+}
+
+// checkError checks that err is nil if code is zero, or that gonzo replied with code.
+func checkError(t *testing.T, err error, code nsm.Code) {
+	t.Helper()
+
+	if code == 0 {
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		return
+	}
+	if err == nil {
+		t.Fatalf("expected error code %d, got no error", code)
+	}
+	if got, ok := gonzo.ErrorCode(err); !ok || got != code {
+		t.Fatalf("expected error code %d, got %s", code, err)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	for _, testcase := range []struct {
+		name     string
+		current  string
+		failure  *gonzotest.Failure
+		code     nsm.Code
+		requests int
+		clients  int
+	}{
+		{name: "adds client", current: "show", requests: 1, clients: 1},
+		{name: "no session open", code: nsm.ErrNoSessionOpen, requests: 1},
+		{name: "launch failed", current: "show", failure: &gonzotest.Failure{Code: nsm.ErrLaunchFailed}, code: nsm.ErrLaunchFailed, requests: 1},
+
+		// The client is there when the add is checked, so it is not sent again.
+		{name: "dropped reply", current: "show", failure: &gonzotest.Failure{Drop: true}, requests: 1, clients: 1},
+
+		// A slow reply must not be taken for a lost one, or the client is added twice.
+		{name: "slow reply", current: "show", failure: &gonzotest.Failure{Delay: testTimeout / 2}, requests: 1, clients: 1},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			s, c := newTestClient(t)
+			s.AddSession("show")
+			s.SetCurrent(testcase.current)
+
+			if testcase.failure != nil {
+				s.Fail(nsm.AddressServerAdd, *testcase.failure)
+			}
+			checkError(t, c.Add(context.Background(), "synth", "zynaddsubfx"), testcase.code)
+
+			if expected, got := testcase.requests, len(s.Requests(nsm.AddressServerAdd)); expected != got {
+				t.Fatalf("expected %d add requests, got %d", expected, got)
+			}
+			if expected, got := testcase.clients, len(s.Clients("show")); expected != got {
+				t.Fatalf("expected %d clients, got %d", expected, got)
+			}
+		})
+	}
+}
+
+func TestClients(t *testing.T) {
+	synth := gonzotest.Client{
+		Name:         "synth",
+		Executable:   "zynaddsubfx",
+		ID:           "nABCD",
+		PID:          1234,
+		Capabilities: nsm.Capabilities{nsm.CapGUI, nsm.CapClientDirty},
+		Status:       []string{gonzo.StatusRunning, gonzo.StatusDirty},
+	}
+	for _, testcase := range []struct {
+		name     string
+		current  string
+		failure  *gonzotest.Failure
+		code     nsm.Code
+		timeout  bool
+		parseErr bool
+		requests int
+		expected []gonzo.ClientInfo
+	}{
+		{
+			name:     "lists clients",
+			current:  "show",
+			requests: 1,
+			expected: []gonzo.ClientInfo{{
+				Name:         "synth",
+				Executable:   "zynaddsubfx",
+				ID:           "nABCD",
+				PID:          1234,
+				Capabilities: nsm.Capabilities{nsm.CapGUI, nsm.CapClientDirty},
+				Status:       []string{gonzo.StatusRunning, gonzo.StatusDirty},
+			}},
+		},
+		{name: "no session open", code: nsm.ErrNoSessionOpen, requests: 1},
+		{name: "error code", current: "show", failure: &gonzotest.Failure{Code: nsm.ErrNotNow}, code: nsm.ErrNotNow, requests: 1},
+		{name: "missing arguments", current: "show", failure: &gonzotest.Failure{Trim: 1}, parseErr: true, requests: 1},
+		{
+			name:     "wrong number of clients",
+			current:  "show",
+			failure:  &gonzotest.Failure{Arguments: osc.Arguments{osc.Int(2), osc.String("synth"), osc.String("zynaddsubfx"), osc.String("nABCD"), osc.Int(1234), osc.String(""), osc.String("")}},
+			parseErr: true,
+			requests: 1,
+		},
+		{name: "dropped reply is retried", current: "show", failure: &gonzotest.Failure{Drop: true, Times: 1}, requests: 2, expected: []gonzo.ClientInfo{{
+			Name:         "synth",
+			Executable:   "zynaddsubfx",
+			ID:           "nABCD",
+			PID:          1234,
+			Capabilities: nsm.Capabilities{nsm.CapGUI, nsm.CapClientDirty},
+			Status:       []string{gonzo.StatusRunning, gonzo.StatusDirty},
+		}}},
+		{name: "every reply dropped", current: "show", failure: &gonzotest.Failure{Drop: true}, timeout: true},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			s, c := newTestClient(t)
+			s.AddSession("show", synth)
+			s.SetCurrent(testcase.current)
+
+			if testcase.failure != nil {
+				s.Fail(nsm.AddressServerClients, *testcase.failure)
+			}
+			clients, err := c.Clients(context.Background())
+
+			switch {
+			case testcase.timeout:
+				if errors.Cause(err) != gonzo.ErrTimeout {
+					t.Fatalf("expected timeout, got %v", err)
+				}
+				// How many attempts fit in the timeout depends on the backoff.
+				if got := len(s.Requests(nsm.AddressServerClients)); got < 2 {
+					t.Fatalf("expected the request to be resent, got %d requests", got)
+				}
+				return
+			case testcase.parseErr:
+				if _, ok := gonzo.ErrorCode(err); err == nil || ok {
+					t.Fatalf("expected parse error, got %v", err)
+				}
+			default:
+				checkError(t, err, testcase.code)
+			}
+			if expected, got := testcase.expected, clients; !reflect.DeepEqual(expected, got) {
+				t.Fatalf("expected clients %#v, got %#v", expected, got)
+			}
+			if expected, got := testcase.requests, len(s.Requests(nsm.AddressServerClients)); expected != got {
+				t.Fatalf("expected %d requests, got %d", expected, got)
+			}
+		})
+	}
+}
+
+func TestLogs(t *testing.T) {
+	synth := gonzotest.Client{
+		Name:       "synth",
+		Executable: "zynaddsubfx",
+		ID:         "nABCD",
+		Stdout:     []string{"starting", "ready"},
+		Stderr:     []string{"no jack"},
+	}
+	for _, testcase := range []struct {
+		name     string
+		client   string
+		stream   string
+		failure  *gonzotest.Failure
+		code     nsm.Code
+		fails    bool
+		expected []string
+	}{
+		{name: "stdout", client: "synth", stream: gonzo.StreamStdout, expected: []string{"starting", "ready"}},
+		{name: "stderr", client: "synth", stream: gonzo.StreamStderr, expected: []string{"no jack"}},
+		{name: "no such client", client: "drums", stream: gonzo.StreamStderr, code: nsm.ErrGeneral},
+		{name: "bad stream", client: "synth", stream: "stdin", fails: true},
+		{name: "missing lines", client: "synth", stream: gonzo.StreamStdout, failure: &gonzotest.Failure{Trim: 1}, fails: true},
+		{name: "wrong number of lines", client: "synth", stream: gonzo.StreamStdout, failure: &gonzotest.Failure{Arguments: osc.Arguments{osc.String("synth"), osc.Int(3), osc.String("starting")}}, fails: true},
+		{name: "slow reply", client: "synth", stream: gonzo.StreamStderr, failure: &gonzotest.Failure{Delay: testTimeout / 10}, expected: []string{"no jack"}},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			s, c := newTestClient(t)
+			s.AddSession("show", synth)
+			s.SetCurrent("show")
+
+			if testcase.failure != nil {
+				s.Fail(nsm.AddressClientLogs, *testcase.failure)
+			}
+			lines, err := c.Logs(context.Background(), testcase.client, testcase.stream)
+			if testcase.fails {
+				if err == nil {
+					t.Fatal("expected error, got none")
+				}
+			} else {
+				checkError(t, err, testcase.code)
+			}
+			if expected, got := testcase.expected, lines; !reflect.DeepEqual(expected, got) {
+				t.Fatalf("expected lines %q, got %q", expected, got)
+			}
+		})
+	}
+}
+
+func TestSetGUI(t *testing.T) {
+	synth := gonzotest.Client{
+		Name:         "synth",
+		Executable:   "zynaddsubfx",
+		ID:           "nABCD",
+		Capabilities: nsm.Capabilities{nsm.CapGUI},
+		Status:       []string{gonzo.StatusRunning},
+	}
+	for _, testcase := range []struct {
+		name    string
+		client  string
+		show    bool
+		code    nsm.Code
+		visible bool
+	}{
+		{name: "show", client: "synth", show: true, visible: true},
+		{name: "hide", client: "synth", show: false, visible: false},
+		{name: "no such client", client: "drums", show: true, code: nsm.ErrGeneral},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			s, c := newTestClient(t)
+			s.AddSession("show", synth)
+			s.SetCurrent("show")
+
+			// Something else reads Events all the time, like the dashboard does.
+			done := make(chan struct{})
+			defer close(done)
+			go func() {
+				for {
+					select {
+					case <-c.Events():
+					case <-done:
+						return
+					}
+				}
+			}()
+			checkError(t, c.SetGUI(context.Background(), testcase.client, testcase.show), testcase.code)
+
+			if testcase.code != 0 {
+				return
+			}
+			clients, err := c.Clients(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected, got := testcase.visible, clients[0].GUIVisible(); expected != got {
+				t.Fatalf("expected GUI visible to be %t, got %t", expected, got)
+			}
+		})
+	}
+}
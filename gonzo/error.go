@@ -0,0 +1,65 @@
+package gonzo
+
+import (
+	"github.com/pkg/errors"
+	"github.com/scgolang/nsm"
+)
+
+// ErrTimeout is returned when gonzo does not reply to a request in time.
+var ErrTimeout = errors.New("timeout")
+
+// Error represents the data contained in an error response from a non session manager.
+type Error struct {
+	nsmErr  nsm.Error
+	Address string
+}
+
+// Code returns the nsm error code that gonzo replied with.
+func (e Error) Code() nsm.Code {
+	return e.nsmErr.Code()
+}
+
+func (e Error) Error() string {
+	desc, ok := codeDescriptions[e.Code()]
+	if !ok {
+		return e.nsmErr.Error()
+	}
+	if msg := e.nsmErr.Error(); msg != "" {
+		return desc + ": " + msg
+	}
+	return desc
+}
+
+// NewError creates a new error.
+func NewError(nsmErr nsm.Error, addr string) Error {
+	return Error{nsmErr: nsmErr, Address: addr}
+}
+
+// codeDescriptions describes the error codes that gonzo can reply with.
+var codeDescriptions = map[nsm.Code]string{
+	nsm.ErrGeneral:         "general error",
+	nsm.ErrIncompatibleAPI: "incompatible API version",
+	nsm.ErrBlacklisted:     "client is blacklisted",
+	nsm.ErrLaunchFailed:    "client failed to launch",
+	nsm.ErrNoSuchFile:      "no such session",
+	nsm.ErrNoSessionOpen:   "no session is open",
+	nsm.ErrUnsavedChanges:  "current session has unsaved changes",
+	nsm.ErrNotNow:          "server is busy, try again later",
+	nsm.ErrBadProject:      "bad session",
+	nsm.ErrCreateFailed:    "could not create session",
+}
+
+// CodeDescription describes an error code that gonzo can reply with.
+func CodeDescription(code nsm.Code) string {
+	return codeDescriptions[code]
+}
+
+// ErrorCode returns the nsm error code of an error that gonzo replied with.
+// The second return value is false if err is not an error reply from gonzo.
+func ErrorCode(err error) (nsm.Code, bool) {
+	e, ok := errors.Cause(err).(Error)
+	if !ok {
+		return 0, false
+	}
+	return e.Code(), true
+}
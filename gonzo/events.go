@@ -0,0 +1,155 @@
+package gonzo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/nsm"
+	"github.com/scgolang/osc"
+)
+
+// Addresses used by gonzo that are not part of the nsm API.
+//
+// Events are an extension of the nsm API that a gonzo server has to implement,
+// nsm servers and older gonzo servers do not support them:
+//
+//   - A client of the server subscribes by sending AddressWatch, and the server replies with /reply.
+//     The server sends events to the address the subscription came from until it restarts.
+//   - The server forwards the nsm client messages listed in eventTypes to its subscribers,
+//     with the name of the client inserted as the first argument.
+//   - AddressClientLaunched has the name and the PID of a client that was launched,
+//     and AddressClientExited has the name and the exit code of a client that exited.
+//
+// A server without this support replies to AddressWatch with an error or not at all.
+const (
+	AddressClientExited   = "/gonzo/client/exited"
+	AddressClientLaunched = "/gonzo/client/launched"
+	AddressPing           = "/ping"
+	AddressPong           = "/pong"
+	AddressWatch          = "/gonzo/watch"
+)
+
+// Event types.
+const (
+	EventClean         = "clean"
+	EventDirty         = "dirty"
+	EventExited        = "exited"
+	EventGUIHidden     = "gui_hidden"
+	EventGUIShowing    = "gui_showing"
+	EventLaunched      = "launched"
+	EventProgress      = "progress"
+	EventSessionLoaded = "session_loaded"
+	EventStatus        = "status"
+)
+
+// eventTypes maps event addresses to event types.
+var eventTypes = map[string]string{
+	AddressClientExited:              EventExited,
+	AddressClientLaunched:            EventLaunched,
+	nsm.AddressClientGUIHidden:       EventGUIHidden,
+	nsm.AddressClientGUIShowing:      EventGUIShowing,
+	nsm.AddressClientIsClean:         EventClean,
+	nsm.AddressClientIsDirty:         EventDirty,
+	nsm.AddressClientProgress:        EventProgress,
+	nsm.AddressClientSessionIsLoaded: EventSessionLoaded,
+	nsm.AddressClientStatus:          EventStatus,
+}
+
+// Event is something that happened to a client of a gonzo server.
+type Event struct {
+	Time     time.Time `json:"time"`
+	Type     string    `json:"type"`
+	Client   string    `json:"client"`
+	Progress *float32  `json:"progress,omitempty"`
+	Priority *int32    `json:"priority,omitempty"`
+	Message  string    `json:"message,omitempty"`
+	ExitCode *int32    `json:"exit_code,omitempty"`
+	PID      *int32    `json:"pid,omitempty"`
+}
+
+// Detail returns a human-readable description of the event's arguments.
+func (e Event) Detail() string {
+	switch {
+	case e.Progress != nil:
+		return fmt.Sprintf("%.0f%%", *e.Progress*100)
+	case e.Priority != nil:
+		return fmt.Sprintf("[priority %d] %s", *e.Priority, e.Message)
+	case e.ExitCode != nil:
+		return fmt.Sprintf("with code %d", *e.ExitCode)
+	case e.PID != nil:
+		return fmt.Sprintf("pid %d", *e.PID)
+	}
+	return ""
+}
+
+// Subscribe asks gonzo to send events for all of its clients.
+// It fails if the server does not support events, see AddressWatch.
+// gonzo forgets subscribers when it restarts, so long-lived subscribers
+// should subscribe again every now and then.
+func (c *Client) Subscribe(ctx context.Context) error {
+	_, err := c.requestRetry(ctx, osc.Message{Address: AddressWatch})
+	return err
+}
+
+// parseEvent parses an event that gonzo forwards from one of its clients.
+// The first argument is the name of the client, the rest are the arguments of the original message.
+func parseEvent(msg osc.Message) (Event, error) {
+	typ, ok := eventTypes[msg.Address]
+	if !ok {
+		return Event{}, errors.Errorf("unrecognized event address %s", msg.Address)
+	}
+	if len(msg.Arguments) < 1 {
+		return Event{}, errors.Errorf("expected client name in %s", msg.Address)
+	}
+	client, err := msg.Arguments[0].ReadString()
+	if err != nil {
+		return Event{}, errors.Wrap(err, "reading client name")
+	}
+	ev := Event{Time: time.Now(), Type: typ, Client: client}
+	args := msg.Arguments[1:]
+
+	switch typ {
+	case EventProgress:
+		if expected, got := 1, len(args); expected != got {
+			return Event{}, errors.Errorf("expected %d arguments after client name, got %d", expected, got)
+		}
+		progress, err := args[0].ReadFloat32()
+		if err != nil {
+			return Event{}, errors.Wrap(err, "reading progress")
+		}
+		ev.Progress = &progress
+	case EventStatus:
+		if expected, got := 2, len(args); expected != got {
+			return Event{}, errors.Errorf("expected %d arguments after client name, got %d", expected, got)
+		}
+		priority, err := args[0].ReadInt32()
+		if err != nil {
+			return Event{}, errors.Wrap(err, "reading priority")
+		}
+		if ev.Message, err = args[1].ReadString(); err != nil {
+			return Event{}, errors.Wrap(err, "reading message")
+		}
+		ev.Priority = &priority
+	case EventExited:
+		if expected, got := 1, len(args); expected != got {
+			return Event{}, errors.Errorf("expected %d arguments after client name, got %d", expected, got)
+		}
+		code, err := args[0].ReadInt32()
+		if err != nil {
+			return Event{}, errors.Wrap(err, "reading exit code")
+		}
+		ev.ExitCode = &code
+	case EventLaunched:
+		if expected, got := 1, len(args); expected != got {
+			return Event{}, errors.Errorf("expected %d arguments after client name, got %d", expected, got)
+		}
+		pid, err := args[0].ReadInt32()
+		if err != nil {
+			return Event{}, errors.Wrap(err, "reading pid")
+		}
+		ev.PID = &pid
+	}
+	return ev, nil
+}
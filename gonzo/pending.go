@@ -0,0 +1,133 @@
+package gonzo
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+)
+
+// waiter waits for the reply to a single request.
+type waiter struct {
+	errors  chan Error
+	replies chan osc.Message
+}
+
+// pendingRequests correlates the replies and errors that gonzo sends
+// with the requests that are waiting for them.
+// Replies and errors name the address of the request they answer in their first argument,
+// so waiters are keyed by that address. Requests for the same address are answered in
+// the order they were sent.
+type pendingRequests struct {
+	mu      sync.Mutex
+	waiters map[string][]*waiter
+}
+
+// newPendingRequests creates an empty table of pending requests.
+func newPendingRequests() *pendingRequests {
+	return &pendingRequests{waiters: map[string][]*waiter{}}
+}
+
+// add adds a waiter for a request to the provided address.
+func (p *pendingRequests) add(addr string) *waiter {
+	w := &waiter{
+		errors:  make(chan Error, 1),
+		replies: make(chan osc.Message, 1),
+	}
+	p.mu.Lock()
+	p.waiters[addr] = append(p.waiters[addr], w)
+	p.mu.Unlock()
+	return w
+}
+
+// remove removes a waiter that is no longer interested in a reply.
+func (p *pendingRequests) remove(addr string, w *waiter) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ws := p.waiters[addr]
+	for i, x := range ws {
+		if x == w {
+			ws = append(ws[:i], ws[i+1:]...)
+			break
+		}
+	}
+	if len(ws) == 0 {
+		delete(p.waiters, addr)
+		return
+	}
+	p.waiters[addr] = ws
+}
+
+// take removes and returns the oldest waiter for the provided address.
+// It returns nil if no request to that address is pending.
+func (p *pendingRequests) take(addr string) *waiter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ws := p.waiters[addr]
+	if len(ws) == 0 {
+		return nil
+	}
+	w := ws[0]
+	if len(ws) == 1 {
+		delete(p.waiters, addr)
+	} else {
+		p.waiters[addr] = ws[1:]
+	}
+	return w
+}
+
+// request sends a message to gonzo and waits for either a reply or an error.
+// It gives up after the client's timeout.
+func (c *Client) request(ctx context.Context, msg osc.Message) (osc.Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	return c.requestContext(ctx, msg)
+}
+
+// requestContext sends a message to gonzo and waits for either a reply or an error,
+// or for ctx to be done.
+func (c *Client) requestContext(ctx context.Context, msg osc.Message) (osc.Message, error) {
+	w, err := c.send(msg)
+	if err != nil {
+		return osc.Message{}, err
+	}
+	return c.wait(ctx, msg, w)
+}
+
+// send sends a message to gonzo and returns the waiter for its reply.
+func (c *Client) send(msg osc.Message) (*waiter, error) {
+	w := c.pending.add(msg.Address)
+
+	if err := c.conn.Send(msg); err != nil {
+		c.pending.remove(msg.Address, w)
+		return nil, errors.Wrap(err, "sending "+msg.Address)
+	}
+	return w, nil
+}
+
+// wait waits for the reply to a message that was sent with send,
+// or for ctx to be done.
+func (c *Client) wait(ctx context.Context, msg osc.Message, w *waiter) (osc.Message, error) {
+	c.logf("waiting for reply to %s", msg.Address)
+
+	select {
+	case err := <-w.errors:
+		return osc.Message{}, err
+	case reply := <-w.replies:
+		c.logf("got reply %s", reply)
+		return reply, nil
+	case <-c.done:
+		c.pending.remove(msg.Address, w)
+		return osc.Message{}, c.Err()
+	case <-ctx.Done():
+		c.pending.remove(msg.Address, w)
+		if ctx.Err() == context.DeadlineExceeded {
+			return osc.Message{}, ErrTimeout
+		}
+		return osc.Message{}, ctx.Err()
+	}
+}
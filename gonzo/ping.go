@@ -0,0 +1,79 @@
+package gonzo
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+)
+
+// Ping sends a ping message and waits for the pong.
+// It returns the round trip time.
+// Pings that go unanswered are retried like other read-only requests.
+func (c *Client) Ping(ctx context.Context) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	attemptTimeout := c.attemptTimeout()
+
+	for attempt := 0; attempt <= c.Retries; attempt++ {
+		// A pong that arrived after an earlier attempt gave up does not answer this one.
+		c.drainPongs()
+
+		start := time.Now()
+
+		if err := c.conn.Send(osc.Message{Address: AddressPing}); err != nil {
+			return 0, errors.Wrap(err, "sending ping")
+		}
+		rtt, err := c.waitPong(ctx, start, attemptTimeout)
+		if err != ErrTimeout || ctx.Err() != nil {
+			return rtt, err
+		}
+		c.logf("no pong after attempt %d", attempt+1)
+
+		if attempt < c.Retries {
+			if err := sleepContext(ctx, backoff(attempt)); err != nil {
+				return 0, pingErr(err)
+			}
+		}
+	}
+	return 0, ErrTimeout
+}
+
+// waitPong waits up to timeout for the pong to a ping sent at start.
+func (c *Client) waitPong(ctx context.Context, start time.Time, timeout time.Duration) (time.Duration, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-c.pongs:
+		return time.Since(start), nil
+	case <-c.done:
+		return 0, c.Err()
+	case <-ctx.Done():
+		return 0, pingErr(ctx.Err())
+	case <-timer.C:
+		return 0, ErrTimeout
+	}
+}
+
+// drainPongs drops the pongs that are waiting to be read.
+func (c *Client) drainPongs() {
+	for {
+		select {
+		case <-c.pongs:
+			c.logf("dropping late pong")
+		default:
+			return
+		}
+	}
+}
+
+// pingErr returns ErrTimeout if the deadline of a ping passed, or err otherwise.
+func pingErr(err error) error {
+	if err == context.DeadlineExceeded {
+		return ErrTimeout
+	}
+	return err
+}
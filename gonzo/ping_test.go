@@ -0,0 +1,63 @@
+package gonzo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/scgolang/gonzoctl/gonzo"
+	"github.com/scgolang/gonzoctl/gonzotest"
+)
+
+func TestPing(t *testing.T) {
+	s, c := newTestClient(t)
+
+	if _, err := c.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	s.Fail(gonzotest.AddressPing, gonzotest.Failure{Drop: true, Times: 1})
+
+	if _, err := c.Ping(context.Background()); err != nil {
+		t.Fatalf("expected the ping to be retried, got %s", err)
+	}
+}
+
+func TestPingLatePong(t *testing.T) {
+	s, c := newTestClient(t)
+	c.Retries = 0
+
+	s.Fail(gonzotest.AddressPing, gonzotest.Failure{Delay: 300 * time.Millisecond, Times: 1})
+	s.Fail(gonzotest.AddressPing, gonzotest.Failure{Drop: true})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.Ping(ctx); err != gonzo.ErrTimeout {
+		t.Fatalf("expected %s, got %v", gonzo.ErrTimeout, err)
+	}
+	// Let the pong to the first ping arrive after it gave up.
+	time.Sleep(400 * time.Millisecond)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.Ping(ctx); err != gonzo.ErrTimeout {
+		t.Fatalf("expected the late pong to be dropped and %s, got %v", gonzo.ErrTimeout, err)
+	}
+}
+
+func TestPingCancel(t *testing.T) {
+	s, c := newTestClient(t)
+	s.Fail(gonzotest.AddressPing, gonzotest.Failure{Drop: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	if _, err := c.Ping(ctx); err != context.Canceled {
+		t.Fatalf("expected %s, got %v", context.Canceled, err)
+	}
+	if elapsed := time.Since(start); elapsed > testTimeout/2 {
+		t.Fatalf("expected the ping to stop when the context is canceled, it took %s", elapsed)
+	}
+}
@@ -0,0 +1,111 @@
+package gonzo
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+)
+
+// Retry settings.
+const (
+	// DefaultRetries is the default number of times a request is resent.
+	DefaultRetries = 3
+
+	// retryBackoff is the delay before the first retry.
+	// It doubles with every retry.
+	retryBackoff = 100 * time.Millisecond
+)
+
+// requestRetry sends a request that is safe to repeat, e.g. one that only reads gonzo's state.
+// If no reply arrives the request is resent up to c.Retries times with exponential backoff
+// and jitter. Each attempt waits for an equal share of c.Timeout, so the request as a whole
+// still fails after c.Timeout. Only timeouts are retried, errors from gonzo are returned.
+func (c *Client) requestRetry(ctx context.Context, msg osc.Message) (osc.Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	attemptTimeout := c.attemptTimeout()
+
+	for attempt := 0; ; attempt++ {
+		actx, acancel := context.WithTimeout(ctx, attemptTimeout)
+		reply, err := c.requestContext(actx, msg)
+		acancel()
+
+		if err != ErrTimeout || attempt >= c.Retries || ctx.Err() != nil {
+			return reply, err
+		}
+		c.logf("no reply to %s after attempt %d", msg.Address, attempt+1)
+
+		if err := sleepContext(ctx, backoff(attempt)); err != nil {
+			return osc.Message{}, ErrTimeout
+		}
+	}
+}
+
+// requestVerified sends a request that changes gonzo's state.
+// gonzo can take a while to apply a request, e.g. adding a client launches it,
+// and a slow reply can not be told apart from a lost one. So the request waits
+// for its reply for the whole c.Timeout, and it is only resent if it could not be sent.
+// If no reply arrives then applied is called to check whether gonzo applied the request
+// and only the reply was lost, in which case requestVerified returns an empty reply and no error.
+// A request that gets no reply is never resent, so it never takes effect twice.
+func (c *Client) requestVerified(ctx context.Context, msg osc.Message, applied func() (bool, error)) (osc.Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	for attempt := 0; ; attempt++ {
+		w, err := c.send(msg)
+		if err != nil {
+			if attempt >= c.Retries || ctx.Err() != nil {
+				return osc.Message{}, err
+			}
+			c.logf("%s, attempt %d", err, attempt+1)
+
+			if err := sleepContext(ctx, backoff(attempt)); err != nil {
+				return osc.Message{}, ErrTimeout
+			}
+			continue
+		}
+		reply, err := c.wait(ctx, msg, w)
+		if err != ErrTimeout {
+			return reply, err
+		}
+		c.logf("no reply to %s, checking whether it was applied", msg.Address)
+
+		ok, aerr := applied()
+		if aerr != nil {
+			return osc.Message{}, errors.Wrap(aerr, "checking whether "+msg.Address+" was applied")
+		}
+		if ok {
+			c.logf("%s was applied, the reply was lost", msg.Address)
+			return osc.Message{}, nil
+		}
+		return reply, err
+	}
+}
+
+// sleepContext waits for d, or returns the error of ctx if it is done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// attemptTimeout returns how long a single attempt of a request that is safe to repeat waits for a reply.
+func (c *Client) attemptTimeout() time.Duration {
+	return c.Timeout / time.Duration(c.Retries+1)
+}
+
+// backoff returns how long to wait before the retry that follows the provided attempt.
+// The delay doubles with every attempt and is randomized by up to half,
+// so that clients that lost packets at the same time do not retry in lockstep.
+func backoff(attempt int) time.Duration {
+	d := retryBackoff << uint(attempt)
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
@@ -0,0 +1,241 @@
+package gonzo
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/nsm"
+	"github.com/scgolang/osc"
+)
+
+// Session describes a session managed by a gonzo server.
+type Session struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Current bool   `json:"current"`
+}
+
+// FindSession returns the session with the provided name or path.
+func FindSession(sessions []Session, name string) (Session, bool) {
+	for _, session := range sessions {
+		if session.Name == name || session.Path == name {
+			return session, true
+		}
+	}
+	return Session{}, false
+}
+
+// Sessions gets the sessions managed by a gonzo server.
+// current is the index of the current session, or -1 if no session is open.
+func (c *Client) Sessions(ctx context.Context) (sessions []Session, current int, err error) {
+	reply, err := c.requestRetry(ctx, osc.Message{
+		Address: nsm.AddressServerSessions,
+	})
+	if err != nil {
+		return nil, -1, errors.Wrap(err, "listing sessions")
+	}
+	sessions, current, err = parseSessions(reply)
+	if err != nil {
+		return nil, -1, errors.Wrap(err, "parsing sessions")
+	}
+	return sessions, current, nil
+}
+
+// NewSession creates a new session and makes it the current session.
+func (c *Client) NewSession(ctx context.Context, name string) error {
+	created := func() (bool, error) {
+		return c.hasSession(ctx, name)
+	}
+	if _, err := c.requestVerified(ctx, osc.Message{
+		Address: nsm.AddressServerNew,
+		Arguments: osc.Arguments{
+			osc.String(name),
+		},
+	}, created); err != nil {
+		return errors.Wrap(err, "creating session "+name)
+	}
+	return nil
+}
+
+// OpenSession opens an existing session.
+func (c *Client) OpenSession(ctx context.Context, name string) error {
+	opened := func() (bool, error) {
+		sessions, _, err := c.Sessions(ctx)
+		if err != nil {
+			return false, err
+		}
+		session, ok := FindSession(sessions, name)
+		return ok && session.Current, nil
+	}
+	if _, err := c.requestVerified(ctx, osc.Message{
+		Address: nsm.AddressServerOpen,
+		Arguments: osc.Arguments{
+			osc.String(name),
+		},
+	}, opened); err != nil {
+		return errors.Wrap(err, "opening session "+name)
+	}
+	return nil
+}
+
+// SaveSession saves the current session.
+func (c *Client) SaveSession(ctx context.Context) error {
+	if _, err := c.requestRetry(ctx, osc.Message{
+		Address: nsm.AddressServerSave,
+	}); err != nil {
+		return errors.Wrap(err, "saving session")
+	}
+	return nil
+}
+
+// CloseSession saves and closes the current session.
+func (c *Client) CloseSession(ctx context.Context) error {
+	if _, err := c.requestVerified(ctx, osc.Message{
+		Address: nsm.AddressServerClose,
+	}, c.noSessionOpen(ctx)); err != nil {
+		return errors.Wrap(err, "closing session")
+	}
+	return nil
+}
+
+// AbortSession closes the current session without saving.
+func (c *Client) AbortSession(ctx context.Context) error {
+	if _, err := c.requestVerified(ctx, osc.Message{
+		Address: nsm.AddressServerAbort,
+	}, c.noSessionOpen(ctx)); err != nil {
+		return errors.Wrap(err, "aborting session")
+	}
+	return nil
+}
+
+// DuplicateSession copies the session src to a new session dst.
+func (c *Client) DuplicateSession(ctx context.Context, src, dst string) error {
+	duplicated := func() (bool, error) {
+		return c.hasSession(ctx, dst)
+	}
+	if _, err := c.requestVerified(ctx, osc.Message{
+		Address: nsm.AddressServerDuplicate,
+		Arguments: osc.Arguments{
+			osc.String(src),
+			osc.String(dst),
+		},
+	}, duplicated); err != nil {
+		return errors.Wrapf(err, "duplicating session %s to %s", src, dst)
+	}
+	return nil
+}
+
+// RemoveSession removes a session.
+func (c *Client) RemoveSession(ctx context.Context, name string) error {
+	removed := func() (bool, error) {
+		ok, err := c.hasSession(ctx, name)
+		return !ok, err
+	}
+	if _, err := c.requestVerified(ctx, osc.Message{
+		Address: nsm.AddressServerRemove,
+		Arguments: osc.Arguments{
+			osc.String(name),
+		},
+	}, removed); err != nil {
+		return errors.Wrap(err, "removing session "+name)
+	}
+	return nil
+}
+
+// MoveSession renames a session.
+// It duplicates the session, then removes the original.
+// If the original can not be removed the copy is removed so that only one
+// of the two sessions remains.
+func (c *Client) MoveSession(ctx context.Context, src, dst string) error {
+	if err := c.DuplicateSession(ctx, src, dst); err != nil {
+		return err
+	}
+	if err := c.RemoveSession(ctx, src); err != nil {
+		c.logf("rolling back rename of %s to %s", src, dst)
+
+		if rerr := c.RemoveSession(ctx, dst); rerr != nil {
+			return errors.Wrapf(err, "renaming %s to %s (rollback failed: %s)", src, dst, rerr)
+		}
+		return errors.Wrapf(err, "renaming %s to %s (rolled back)", src, dst)
+	}
+	return nil
+}
+
+// Quit tells gonzo to save the current session and exit.
+func (c *Client) Quit(ctx context.Context) error {
+	if _, err := c.request(ctx, osc.Message{
+		Address: nsm.AddressServerQuit,
+	}); err != nil {
+		return errors.Wrap(err, "quitting gonzo")
+	}
+	return nil
+}
+
+// hasSession returns true if gonzo has a session with the provided name.
+func (c *Client) hasSession(ctx context.Context, name string) (bool, error) {
+	sessions, _, err := c.Sessions(ctx)
+	if err != nil {
+		return false, err
+	}
+	_, ok := FindSession(sessions, name)
+	return ok, nil
+}
+
+// noSessionOpen returns a func that returns true if gonzo does not have a current session.
+func (c *Client) noSessionOpen(ctx context.Context) func() (bool, error) {
+	return func() (bool, error) {
+		_, current, err := c.Sessions(ctx)
+		if err != nil {
+			return false, err
+		}
+		return current < 0, nil
+	}
+}
+
+// parseSessions parses sessions from an OSC reply to /nsm/server/list
+// If there is no current session then the returned index is -1.
+func parseSessions(msg osc.Message) ([]Session, int, error) {
+	const minNumArgs = 3
+
+	if len(msg.Arguments) < minNumArgs {
+		return nil, -1, errors.Errorf("expected at least %d arguments", minNumArgs)
+	}
+	addr, err := msg.Arguments[0].ReadString()
+	if err != nil {
+		return nil, -1, errors.Wrap(err, "reading reply address from osc message")
+	}
+	if expected, got := nsm.AddressServerSessions, addr; expected != got {
+		return nil, -1, errors.Errorf("expected reply to %s, got %s", expected, got)
+	}
+	numSessions, err := msg.Arguments[1].ReadInt32()
+	if err != nil {
+		return nil, -1, errors.Wrap(err, "reading number of sessions from osc message")
+	}
+	curridx, err := msg.Arguments[2].ReadInt32()
+	if err != nil {
+		return nil, -1, errors.Wrap(err, "reading current session index from osc message")
+	}
+	if expected, got := numSessions+minNumArgs, int32(len(msg.Arguments)); expected != got {
+		return nil, -1, errors.Errorf("expected %d arguments, got %d", expected, got)
+	}
+	var (
+		current  = -1
+		sessions = make([]Session, numSessions)
+	)
+	for i := int32(0); i < numSessions; i++ {
+		project, err := msg.Arguments[i+minNumArgs].ReadString()
+		if err != nil {
+			return nil, -1, errors.Wrap(err, "reading project from osc message")
+		}
+		sessions[i] = Session{
+			Name:    filepath.Base(project),
+			Path:    project,
+			Current: i == curridx,
+		}
+		if i == curridx {
+			current = int(i)
+		}
+	}
+	return sessions, current, nil
+}
@@ -0,0 +1,160 @@
+package gonzo_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/scgolang/gonzoctl/gonzo"
+	"github.com/scgolang/gonzoctl/gonzotest"
+	"github.com/scgolang/nsm"
+	"github.com/scgolang/osc"
+)
+
+func TestSessions(t *testing.T) {
+	for _, testcase := range []struct {
+		name     string
+		current  string
+		failure  *gonzotest.Failure
+		code     nsm.Code
+		fails    bool
+		requests int
+		sessions []gonzo.Session
+		index    int
+	}{
+		{
+			name:     "current session",
+			current:  "show",
+			requests: 1,
+			sessions: []gonzo.Session{
+				{Name: "rehearsal", Path: gonzotest.DefaultRoot + "/rehearsal"},
+				{Name: "show", Path: gonzotest.DefaultRoot + "/show", Current: true},
+			},
+			index: 1,
+		},
+		{
+			name:     "no session open",
+			requests: 1,
+			sessions: []gonzo.Session{
+				{Name: "rehearsal", Path: gonzotest.DefaultRoot + "/rehearsal"},
+				{Name: "show", Path: gonzotest.DefaultRoot + "/show"},
+			},
+			index: -1,
+		},
+		{name: "error code", failure: &gonzotest.Failure{Code: nsm.ErrNotNow}, code: nsm.ErrNotNow, requests: 1, index: -1},
+		{name: "missing session", failure: &gonzotest.Failure{Trim: 1}, fails: true, requests: 1, index: -1},
+		{name: "wrong number of sessions", failure: &gonzotest.Failure{Arguments: osc.Arguments{osc.Int(3), osc.Int(-1), osc.String(gonzotest.DefaultRoot + "/show")}}, fails: true, requests: 1, index: -1},
+		{
+			name:     "dropped reply is retried",
+			current:  "show",
+			failure:  &gonzotest.Failure{Drop: true, Times: 1},
+			requests: 2,
+			sessions: []gonzo.Session{
+				{Name: "rehearsal", Path: gonzotest.DefaultRoot + "/rehearsal"},
+				{Name: "show", Path: gonzotest.DefaultRoot + "/show", Current: true},
+			},
+			index: 1,
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			s, c := newTestClient(t)
+			s.AddSession("rehearsal")
+			s.AddSession("show")
+			s.SetCurrent(testcase.current)
+
+			if testcase.failure != nil {
+				s.Fail(nsm.AddressServerSessions, *testcase.failure)
+			}
+			sessions, current, err := c.Sessions(context.Background())
+			if testcase.fails {
+				if err == nil {
+					t.Fatal("expected error, got none")
+				}
+			} else {
+				checkError(t, err, testcase.code)
+			}
+			if expected, got := testcase.sessions, sessions; !reflect.DeepEqual(expected, got) {
+				t.Fatalf("expected sessions %#v, got %#v", expected, got)
+			}
+			if expected, got := testcase.index, current; expected != got {
+				t.Fatalf("expected current session %d, got %d", expected, got)
+			}
+			if expected, got := testcase.requests, len(s.Requests(nsm.AddressServerSessions)); expected != got {
+				t.Fatalf("expected %d requests, got %d", expected, got)
+			}
+		})
+	}
+}
+
+func TestNewSession(t *testing.T) {
+	for _, testcase := range []struct {
+		name     string
+		session  string
+		failure  *gonzotest.Failure
+		code     nsm.Code
+		requests int
+		sessions []string
+	}{
+		{name: "creates session", session: "show", requests: 1, sessions: []string{"rehearsal", "show"}},
+		{name: "session exists", session: "rehearsal", code: nsm.ErrCreateFailed, requests: 1, sessions: []string{"rehearsal"}},
+		{name: "create failed", session: "show", failure: &gonzotest.Failure{Code: nsm.ErrCreateFailed}, code: nsm.ErrCreateFailed, requests: 1, sessions: []string{"rehearsal"}},
+		{name: "dropped reply", session: "show", failure: &gonzotest.Failure{Drop: true}, requests: 1, sessions: []string{"rehearsal", "show"}},
+		{name: "slow reply", session: "show", failure: &gonzotest.Failure{Delay: testTimeout / 2}, requests: 1, sessions: []string{"rehearsal", "show"}},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			s, c := newTestClient(t)
+			s.AddSession("rehearsal")
+
+			if testcase.failure != nil {
+				s.Fail(nsm.AddressServerNew, *testcase.failure)
+			}
+			checkError(t, c.NewSession(context.Background(), testcase.session), testcase.code)
+
+			if expected, got := testcase.sessions, s.Sessions(); !reflect.DeepEqual(expected, got) {
+				t.Fatalf("expected sessions %q, got %q", expected, got)
+			}
+			if expected, got := testcase.requests, len(s.Requests(nsm.AddressServerNew)); expected != got {
+				t.Fatalf("expected %d requests, got %d", expected, got)
+			}
+			if testcase.code == 0 && s.Current() != testcase.session {
+				t.Fatalf("expected current session %s, got %s", testcase.session, s.Current())
+			}
+		})
+	}
+}
+
+func TestRemoveSession(t *testing.T) {
+	for _, testcase := range []struct {
+		name     string
+		session  string
+		failure  *gonzotest.Failure
+		code     nsm.Code
+		requests int
+		sessions []string
+	}{
+		{name: "removes session", session: "rehearsal", requests: 1, sessions: []string{"show"}},
+		{name: "no such session", session: "soundcheck", code: nsm.ErrNoSuchFile, requests: 1, sessions: []string{"rehearsal", "show"}},
+		{name: "session is open", session: "show", code: nsm.ErrNotNow, requests: 1, sessions: []string{"rehearsal", "show"}},
+		{name: "dropped reply", session: "rehearsal", failure: &gonzotest.Failure{Drop: true}, requests: 1, sessions: []string{"show"}},
+		{name: "slow reply", session: "rehearsal", failure: &gonzotest.Failure{Delay: testTimeout / 2}, requests: 1, sessions: []string{"show"}},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			s, c := newTestClient(t)
+			s.AddSession("rehearsal")
+			s.AddSession("show")
+			s.SetCurrent("show")
+
+			if testcase.failure != nil {
+				s.Fail(nsm.AddressServerRemove, *testcase.failure)
+			}
+			checkError(t, c.RemoveSession(context.Background(), testcase.session), testcase.code)
+
+			if expected, got := testcase.sessions, s.Sessions(); !reflect.DeepEqual(expected, got) {
+				t.Fatalf("expected sessions %q, got %q", expected, got)
+			}
+			if expected, got := testcase.requests, len(s.Requests(nsm.AddressServerRemove)); expected != got {
+				t.Fatalf("expected %d requests, got %d", expected, got)
+			}
+		})
+	}
+}
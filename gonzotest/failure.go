@@ -0,0 +1,97 @@
+package gonzotest
+
+import (
+	"time"
+
+	"github.com/scgolang/nsm"
+	"github.com/scgolang/osc"
+)
+
+// Failure describes how the server misbehaves when it handles a request.
+type Failure struct {
+	// Code makes the server reply with an error instead of handling the request.
+	Code nsm.Code
+
+	// Message is the message of the error reply.
+	Message string
+
+	// Delay delays the reply.
+	Delay time.Duration
+
+	// Drop makes the server handle the request without replying,
+	// as if the reply got lost on the way.
+	Drop bool
+
+	// Arguments replaces the arguments of the reply, not counting the reply address.
+	Arguments osc.Arguments
+
+	// Trim removes arguments from the end of the reply.
+	Trim int
+
+	// Times is the number of requests the failure applies to.
+	// Zero means every request.
+	Times int
+}
+
+// Fail makes the server fail requests to the provided address.
+// Failures for the same address are applied in the order they were added.
+func (s *Server) Fail(addr string, f Failure) {
+	s.mu.Lock()
+	s.failures[addr] = append(s.failures[addr], f)
+	s.mu.Unlock()
+}
+
+// Reset removes all the failures.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	s.failures = map[string][]Failure{}
+	s.mu.Unlock()
+}
+
+// takeFailure returns the failure for the next request to the provided address.
+// The caller must hold s.mu.
+func (s *Server) takeFailure(addr string) (Failure, bool) {
+	fs := s.failures[addr]
+	if len(fs) == 0 {
+		return Failure{}, false
+	}
+	f := fs[0]
+	switch f.Times {
+	case 0:
+	case 1:
+		if len(fs) == 1 {
+			delete(s.failures, addr)
+		} else {
+			s.failures[addr] = fs[1:]
+		}
+	default:
+		fs[0].Times--
+	}
+	return f, true
+}
+
+// wait waits for the delay of the failure.
+func (f Failure) wait() {
+	if f.Delay > 0 {
+		time.Sleep(f.Delay)
+	}
+}
+
+// message returns the message of the error reply.
+func (f Failure) message() string {
+	if f.Message == "" {
+		return "injected failure"
+	}
+	return f.Message
+}
+
+// mangle applies the failure to the arguments of a reply.
+func (f Failure) mangle(args osc.Arguments) osc.Arguments {
+	if f.Arguments != nil {
+		args = f.Arguments
+	}
+	if f.Trim >= len(args) {
+		return osc.Arguments{}
+	}
+	return args[:len(args)-f.Trim]
+}
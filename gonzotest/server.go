@@ -0,0 +1,588 @@
+// Package gonzotest provides a fake gonzo server for testing.
+// The fake server speaks the /nsm/server/* API that gonzoctl uses
+// and keeps its sessions and clients in memory.
+package gonzotest
+
+import (
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/nsm"
+	"github.com/scgolang/osc"
+)
+
+// Addresses that gonzo handles in addition to the nsm server API.
+const (
+	AddressPing  = "/ping"
+	AddressPong  = "/pong"
+	AddressWatch = "/gonzo/watch"
+)
+
+// Status flags of a client.
+const (
+	StatusDirty      = "dirty"
+	StatusGUIVisible = "gui-visible"
+	StatusRunning    = "running"
+)
+
+// Log streams of a client.
+const (
+	StreamStdout = 1
+	StreamStderr = 2
+)
+
+// DefaultRoot is the directory that session paths are reported in.
+const DefaultRoot = "/gonzotest"
+
+// Client is a client of a session on the fake server.
+type Client struct {
+	Name         string
+	Executable   string
+	ID           string
+	PID          int32
+	Capabilities nsm.Capabilities
+	Status       []string
+	Stdout       []string
+	Stderr       []string
+}
+
+// setStatus sets or clears a status flag of the client.
+func (c *Client) setStatus(flag string, set bool) {
+	status := []string{}
+	for _, s := range c.Status {
+		if s != flag {
+			status = append(status, s)
+		}
+	}
+	if set {
+		status = append(status, flag)
+	}
+	c.Status = status
+}
+
+// Server is a fake gonzo server.
+type Server struct {
+	// Root is the directory that session paths are reported in.
+	Root string
+
+	conn *osc.UDPConn
+	done chan error
+
+	mu       sync.Mutex
+	sessions []string
+	clients  map[string][]Client
+	current  string
+	failures map[string][]Failure
+	requests map[string][]osc.Message
+	watchers map[string]net.Addr
+	nextID   int
+}
+
+// NewServer starts a fake gonzo server on a random port of the loopback interface.
+func NewServer() (*Server, error) {
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		return nil, errors.Wrap(err, "resolving listen address")
+	}
+	conn, err := osc.ListenUDP("udp", laddr)
+	if err != nil {
+		return nil, errors.Wrap(err, "listening on udp")
+	}
+	s := &Server{
+		Root:     DefaultRoot,
+		conn:     conn,
+		done:     make(chan error, 1),
+		clients:  map[string][]Client{},
+		failures: map[string][]Failure{},
+		requests: map[string][]osc.Message{},
+		watchers: map[string]net.Addr{},
+	}
+	go func() {
+		s.done <- conn.Serve(s.dispatcher())
+	}()
+	return s, nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() *net.UDPAddr {
+	return s.conn.LocalAddr().(*net.UDPAddr)
+}
+
+// Host returns the host the server listens on.
+func (s *Server) Host() string {
+	return s.Addr().IP.String()
+}
+
+// Port returns the port the server listens on.
+func (s *Server) Port() int {
+	return s.Addr().Port
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	if err := s.conn.Close(); err != nil {
+		return err
+	}
+	return <-s.done
+}
+
+// AddSession adds a session to the server.
+func (s *Server) AddSession(name string, clients ...Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.hasSession(name) {
+		s.sessions = append(s.sessions, name)
+	}
+	s.clients[name] = append(s.clients[name], clients...)
+}
+
+// SetCurrent makes the named session the current session.
+// An empty name means that no session is open.
+func (s *Server) SetCurrent(name string) {
+	s.mu.Lock()
+	s.current = name
+	s.mu.Unlock()
+}
+
+// Current returns the name of the current session, or an empty string if no session is open.
+func (s *Server) Current() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.current
+}
+
+// Sessions returns the names of the sessions on the server.
+func (s *Server) Sessions() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.sessions...)
+}
+
+// Clients returns the clients of the named session.
+func (s *Server) Clients(session string) []Client {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Client{}, s.clients[session]...)
+}
+
+// Requests returns the requests the server has received for the provided address.
+func (s *Server) Requests(addr string) []osc.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]osc.Message{}, s.requests[addr]...)
+}
+
+// Notify sends an event to every client that subscribed with /gonzo/watch.
+func (s *Server) Notify(msg osc.Message) error {
+	s.mu.Lock()
+	watchers := make([]net.Addr, 0, len(s.watchers))
+	for _, addr := range s.watchers {
+		watchers = append(watchers, addr)
+	}
+	s.mu.Unlock()
+
+	for _, addr := range watchers {
+		if err := s.conn.SendTo(addr, msg); err != nil {
+			return errors.Wrap(err, "sending event to "+addr.String())
+		}
+	}
+	return nil
+}
+
+// dispatcher returns the osc dispatcher for the server.
+func (s *Server) dispatcher() osc.Dispatcher {
+	d := osc.Dispatcher{
+		AddressPing: s.ping,
+	}
+	for addr, h := range map[string]handler{
+		AddressWatch:                     s.watch,
+		nsm.AddressClientLogs:            s.logs,
+		nsm.AddressClientHideOptionalGUI: s.gui(false),
+		nsm.AddressClientShowOptionalGUI: s.gui(true),
+		nsm.AddressServerAbort:           s.close,
+		nsm.AddressServerAdd:             s.add,
+		nsm.AddressServerClients:         s.listClients,
+		nsm.AddressServerClose:           s.close,
+		nsm.AddressServerDuplicate:       s.duplicate,
+		nsm.AddressServerNew:             s.newSession,
+		nsm.AddressServerOpen:            s.open,
+		nsm.AddressServerQuit:            s.close,
+		nsm.AddressServerRemove:          s.remove,
+		nsm.AddressServerSave:            s.save,
+		nsm.AddressServerSessions:        s.listSessions,
+	} {
+		d[addr] = s.handle(h)
+	}
+	return d
+}
+
+// handler handles a request and returns the arguments of the reply.
+// The reply address is prepended to the arguments by the server.
+type handler func(msg osc.Message) (osc.Arguments, error)
+
+// handle wraps a handler with request recording, failure injection and replies.
+func (s *Server) handle(h handler) osc.Method {
+	return func(msg osc.Message) error {
+		s.mu.Lock()
+		s.requests[msg.Address] = append(s.requests[msg.Address], msg)
+		f, failing := s.takeFailure(msg.Address)
+		s.mu.Unlock()
+
+		if failing {
+			f.wait()
+			if f.Code != 0 {
+				return s.replyError(msg, nsm.NewError(f.Code, f.message()))
+			}
+		}
+		args, err := h(msg)
+		if failing && f.Drop {
+			return nil
+		}
+		if err != nil {
+			if nsmErr, ok := err.(nsm.Error); ok {
+				return s.replyError(msg, nsmErr)
+			}
+			return s.replyError(msg, nsm.NewError(nsm.ErrGeneral, err.Error()))
+		}
+		if failing {
+			args = f.mangle(args)
+		}
+		return s.conn.SendTo(msg.Sender, osc.Message{
+			Address:   nsm.AddressReply,
+			Arguments: append(osc.Arguments{osc.String(msg.Address)}, args...),
+		})
+	}
+}
+
+// ping answers a ping with a pong.
+// Only the Delay and Drop of a failure apply to pings.
+func (s *Server) ping(msg osc.Message) error {
+	s.mu.Lock()
+	s.requests[msg.Address] = append(s.requests[msg.Address], msg)
+	f, failing := s.takeFailure(msg.Address)
+	s.mu.Unlock()
+
+	if failing {
+		f.wait()
+		if f.Drop {
+			return nil
+		}
+	}
+	return s.conn.SendTo(msg.Sender, osc.Message{Address: AddressPong})
+}
+
+// replyError sends an error reply for a request.
+func (s *Server) replyError(msg osc.Message, err nsm.Error) error {
+	return s.conn.SendTo(msg.Sender, osc.Message{
+		Address: nsm.AddressError,
+		Arguments: osc.Arguments{
+			osc.String(msg.Address),
+			osc.Int(int32(err.Code())),
+			osc.String(err.Error()),
+		},
+	})
+}
+
+// ok is the reply to requests that succeeded and have nothing else to say.
+var ok = osc.Arguments{osc.String("ok")}
+
+func (s *Server) listSessions(msg osc.Message) (osc.Arguments, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	curridx := -1
+	args := osc.Arguments{osc.Int(int32(len(s.sessions))), nil}
+	for i, name := range s.sessions {
+		if name == s.current {
+			curridx = i
+		}
+		args = append(args, osc.String(path.Join(s.Root, name)))
+	}
+	args[1] = osc.Int(int32(curridx))
+	return args, nil
+}
+
+func (s *Server) listClients(msg osc.Message) (osc.Arguments, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.current == "" {
+		return nil, nsm.NewError(nsm.ErrNoSessionOpen, "no session open")
+	}
+	clients := s.clients[s.current]
+	args := osc.Arguments{osc.Int(int32(len(clients)))}
+	for _, c := range clients {
+		args = append(args,
+			osc.String(c.Name),
+			osc.String(c.Executable),
+			osc.String(c.ID),
+			osc.Int(c.PID),
+			osc.String(joinFlags(capabilityStrings(c.Capabilities))),
+			osc.String(joinFlags(c.Status)),
+		)
+	}
+	return args, nil
+}
+
+func (s *Server) add(msg osc.Message) (osc.Arguments, error) {
+	name, executable, err := readStrings2(msg)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.current == "" {
+		return nil, nsm.NewError(nsm.ErrNoSessionOpen, "no session open")
+	}
+	if _, exists := s.findClient(name); exists {
+		return nil, nsm.NewError(nsm.ErrGeneral, "client "+name+" already exists")
+	}
+	s.nextID++
+	s.clients[s.current] = append(s.clients[s.current], Client{
+		Name:         name,
+		Executable:   executable,
+		ID:           "n" + strconv.Itoa(s.nextID),
+		PID:          int32(1000 + s.nextID),
+		Capabilities: nsm.Capabilities{},
+		Status:       []string{StatusRunning},
+	})
+	return ok, nil
+}
+
+func (s *Server) logs(msg osc.Message) (osc.Arguments, error) {
+	if expected, got := 2, len(msg.Arguments); expected != got {
+		return nil, errors.Errorf("expected %d arguments, got %d", expected, got)
+	}
+	name, err := msg.Arguments[0].ReadString()
+	if err != nil {
+		return nil, errors.Wrap(err, "reading client name")
+	}
+	stream, err := msg.Arguments[1].ReadInt32()
+	if err != nil {
+		return nil, errors.Wrap(err, "reading log stream")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, exists := s.findClient(name)
+	if !exists {
+		return nil, nsm.NewError(nsm.ErrGeneral, "no such client "+name)
+	}
+	client := s.clients[s.current][i]
+	lines := client.Stderr
+	if stream == StreamStdout {
+		lines = client.Stdout
+	}
+	args := osc.Arguments{osc.String(name), osc.Int(int32(len(lines)))}
+	for _, line := range lines {
+		args = append(args, osc.String(line))
+	}
+	return args, nil
+}
+
+// gui returns a handler that shows or hides the optional gui of a client.
+// Like gonzo the fake server confirms the change with a message to the sender.
+func (s *Server) gui(show bool) handler {
+	confirm := nsm.AddressClientGUIHidden
+	if show {
+		confirm = nsm.AddressClientGUIShowing
+	}
+	return func(msg osc.Message) (osc.Arguments, error) {
+		name, err := readString(msg)
+		if err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		i, exists := s.findClient(name)
+		if exists {
+			s.clients[s.current][i].setStatus(StatusGUIVisible, show)
+		}
+		s.mu.Unlock()
+
+		if !exists {
+			return nil, nsm.NewError(nsm.ErrGeneral, "no such client "+name)
+		}
+		go func() {
+			_ = s.conn.SendTo(msg.Sender, osc.Message{
+				Address:   confirm,
+				Arguments: osc.Arguments{osc.String(name)},
+			})
+		}()
+		return ok, nil
+	}
+}
+
+func (s *Server) newSession(msg osc.Message) (osc.Arguments, error) {
+	name, err := readString(msg)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.hasSession(name) {
+		return nil, nsm.NewError(nsm.ErrCreateFailed, "session "+name+" already exists")
+	}
+	s.sessions = append(s.sessions, name)
+	s.current = name
+	return ok, nil
+}
+
+func (s *Server) open(msg osc.Message) (osc.Arguments, error) {
+	name, err := readString(msg)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.hasSession(name) {
+		return nil, nsm.NewError(nsm.ErrNoSuchFile, "no such session "+name)
+	}
+	s.current = name
+	return ok, nil
+}
+
+func (s *Server) save(msg osc.Message) (osc.Arguments, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.current == "" {
+		return nil, nsm.NewError(nsm.ErrNoSessionOpen, "no session open")
+	}
+	for i := range s.clients[s.current] {
+		s.clients[s.current][i].setStatus(StatusDirty, false)
+	}
+	return ok, nil
+}
+
+// close handles close, abort and quit, which all leave the server without a current session.
+func (s *Server) close(msg osc.Message) (osc.Arguments, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.current == "" && msg.Address != nsm.AddressServerQuit {
+		return nil, nsm.NewError(nsm.ErrNoSessionOpen, "no session open")
+	}
+	s.current = ""
+	return ok, nil
+}
+
+func (s *Server) duplicate(msg osc.Message) (osc.Arguments, error) {
+	src, dst, err := readStrings2(msg)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.hasSession(src) {
+		return nil, nsm.NewError(nsm.ErrNoSuchFile, "no such session "+src)
+	}
+	if s.hasSession(dst) {
+		return nil, nsm.NewError(nsm.ErrCreateFailed, "session "+dst+" already exists")
+	}
+	s.sessions = append(s.sessions, dst)
+	s.clients[dst] = append([]Client{}, s.clients[src]...)
+	return ok, nil
+}
+
+func (s *Server) remove(msg osc.Message) (osc.Arguments, error) {
+	name, err := readString(msg)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.hasSession(name) {
+		return nil, nsm.NewError(nsm.ErrNoSuchFile, "no such session "+name)
+	}
+	if name == s.current {
+		return nil, nsm.NewError(nsm.ErrNotNow, "session "+name+" is open")
+	}
+	for i, session := range s.sessions {
+		if session == name {
+			s.sessions = append(s.sessions[:i], s.sessions[i+1:]...)
+			break
+		}
+	}
+	delete(s.clients, name)
+	return ok, nil
+}
+
+func (s *Server) watch(msg osc.Message) (osc.Arguments, error) {
+	s.mu.Lock()
+	s.watchers[msg.Sender.String()] = msg.Sender
+	s.mu.Unlock()
+	return ok, nil
+}
+
+// hasSession returns true if the server has a session with the provided name.
+// The caller must hold s.mu.
+func (s *Server) hasSession(name string) bool {
+	for _, session := range s.sessions {
+		if session == name {
+			return true
+		}
+	}
+	return false
+}
+
+// findClient returns the index of the named client in the current session.
+// The caller must hold s.mu.
+func (s *Server) findClient(name string) (int, bool) {
+	for i, c := range s.clients[s.current] {
+		if c.Name == name {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// readString reads the only argument of a request.
+func readString(msg osc.Message) (string, error) {
+	if expected, got := 1, len(msg.Arguments); expected != got {
+		return "", errors.Errorf("expected %d arguments, got %d", expected, got)
+	}
+	return msg.Arguments[0].ReadString()
+}
+
+// readStrings2 reads the two arguments of a request.
+func readStrings2(msg osc.Message) (string, string, error) {
+	if expected, got := 2, len(msg.Arguments); expected != got {
+		return "", "", errors.Errorf("expected %d arguments, got %d", expected, got)
+	}
+	first, err := msg.Arguments[0].ReadString()
+	if err != nil {
+		return "", "", errors.Wrap(err, "reading first argument")
+	}
+	second, err := msg.Arguments[1].ReadString()
+	if err != nil {
+		return "", "", errors.Wrap(err, "reading second argument")
+	}
+	return first, second, nil
+}
+
+// joinFlags joins flags the way gonzo sends them, e.g. ":running:dirty:".
+func joinFlags(flags []string) string {
+	if len(flags) == 0 {
+		return nsm.CapSep
+	}
+	return nsm.CapSep + strings.Join(flags, nsm.CapSep) + nsm.CapSep
+}
+
+// capabilityStrings converts capabilities to strings.
+func capabilityStrings(caps nsm.Capabilities) []string {
+	ss := make([]string, len(caps))
+	for i, c := range caps {
+		ss[i] = string(c)
+	}
+	return ss
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/gonzoctl/gonzo"
+	"github.com/scgolang/nsm"
+)
+
+// guiActions are the actions the gui command can perform.
+var guiActions = map[string]bool{"hide": true, "show": true, "toggle": true}
+
+// GUI shows or hides the optional GUI of one or more clients.
+func (app *App) GUI(inv *Invocation) error {
+	var (
+		action = inv.Args[0]
+		names  = inv.Args[1:]
+	)
+	if !guiActions[action] {
+		return usageErrorf("expected action to be show, hide or toggle, got %s", action)
+	}
+	list, err := app.clients()
+	if err != nil {
+		return err
+	}
+	clients := map[string]gonzo.ClientInfo{}
+	for _, client := range list {
+		clients[client.Name] = client
+	}
+	for _, name := range names {
+		client, ok := clients[name]
+		if !ok {
+			return usageErrorf("no such client: %s", name)
+		}
+		if !client.HasCapability(nsm.CapGUI) {
+			return errors.Errorf("client %s does not have the %s capability", name, nsm.CapGUI)
+		}
+	}
+	for _, name := range names {
+		show := action == "show" || (action == "toggle" && !clients[name].GUIVisible())
+
+		if err := app.client.SetGUI(app.ctx, name, show); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	registerCommand(&Command{
+		Name:    "gui",
+		Summary: "Show or hide the optional GUI of clients.",
+		Args: []Arg{
+			{Name: "ACTION", Help: "show, hide or toggle.", Complete: func(app *App) []string { return sortedKeys(guiActions) }},
+			{Name: "CLIENT", Help: fmt.Sprintf("The name of a client. The client must have the %s capability.", nsm.CapGUI), Variadic: true, Complete: (*App).clientNames},
+		},
+		Help: func(w io.Writer) {
+			fmt.Fprintf(w, "gui waits for each client to confirm that its GUI is showing or hidden.\n")
+			fmt.Fprintf(w, "\n")
+			fmt.Fprintf(w, "Example:\n")
+			fmt.Fprintf(w, "gonzoctl gui show synth1 synth2\n")
+		},
+		Run: (*App).GUI,
+	})
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// Keys that the line editor handles.
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+// maxHistory is the number of lines that are kept in the history file.
+const maxHistory = 1000
+
+// lineEditor reads lines from a terminal in raw mode.
+// It supports moving the cursor, emacs-style editing keys,
+// history and tab completion.
+type lineEditor struct {
+	fd  int
+	in  *bufio.Reader
+	out io.Writer
+
+	// complete returns the candidates for the word that ends at the end of line.
+	complete func(line string) []string
+
+	history     []string
+	historyFile string
+}
+
+// newLineEditor creates a line editor for stdin and stdout.
+// History is loaded from and saved to historyFile.
+func newLineEditor(historyFile string, complete func(line string) []string) (*lineEditor, error) {
+	e := &lineEditor{
+		fd:          int(os.Stdin.Fd()),
+		in:          bufio.NewReader(os.Stdin),
+		out:         os.Stdout,
+		complete:    complete,
+		historyFile: historyFile,
+	}
+	if err := e.loadHistory(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// readLine reads a line.
+// It returns io.EOF if Ctrl-D is pressed on an empty line.
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	state, err := makeRaw(e.fd)
+	if err != nil {
+		return "", errors.Wrap(err, "setting terminal to raw mode")
+	}
+	defer func() { _ = restoreTerminal(e.fd, state) }()
+
+	var (
+		line   []rune
+		pos    int
+		hist   = len(e.history)
+		edited []rune
+		tabs   int
+	)
+	e.refresh(prompt, line, pos)
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		if r == keyTab {
+			tabs++
+		} else {
+			tabs = 0
+		}
+		switch r {
+		case keyEnter, '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(line), nil
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+			line, pos, hist = nil, 0, len(e.history)
+		case keyCtrlD:
+			if len(line) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			if pos < len(line) {
+				line = append(line[:pos], line[pos+1:]...)
+			}
+		case keyBackspace, keyCtrlH:
+			if pos > 0 {
+				line = append(line[:pos-1], line[pos:]...)
+				pos--
+			}
+		case keyCtrlA:
+			pos = 0
+		case keyCtrlE:
+			pos = len(line)
+		case keyCtrlB:
+			if pos > 0 {
+				pos--
+			}
+		case keyCtrlF:
+			if pos < len(line) {
+				pos++
+			}
+		case keyCtrlK:
+			line = line[:pos]
+		case keyCtrlU:
+			line, pos = append([]rune{}, line[pos:]...), 0
+		case keyCtrlW:
+			start := wordStart(line, pos)
+			line, pos = append(line[:start], line[pos:]...), start
+		case keyCtrlL:
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case keyCtrlP, keyCtrlN:
+			line, hist, edited = e.browse(line, hist, edited, r == keyCtrlP)
+			pos = len(line)
+		case keyTab:
+			line, pos = e.completeWord(line, pos, tabs > 1)
+		case keyEscape:
+			key, err := readEscape(e.in)
+			if err != nil {
+				return "", err
+			}
+			switch key {
+			case 'A', 'B':
+				line, hist, edited = e.browse(line, hist, edited, key == 'A')
+				pos = len(line)
+			case 'C':
+				if pos < len(line) {
+					pos++
+				}
+			case 'D':
+				if pos > 0 {
+					pos--
+				}
+			case 'H':
+				pos = 0
+			case 'F':
+				pos = len(line)
+			case '3':
+				if pos < len(line) {
+					line = append(line[:pos], line[pos+1:]...)
+				}
+			}
+		default:
+			if unicode.IsPrint(r) {
+				line = append(line, 0)
+				copy(line[pos+1:], line[pos:])
+				line[pos] = r
+				pos++
+			}
+		}
+		e.refresh(prompt, line, pos)
+	}
+}
+
+// readEscape reads the rest of an escape sequence, e.g. an arrow key.
+// It returns the final byte of the sequence, or the first parameter
+// for sequences like ESC [ 3 ~ (delete).
+// Home and End are returned as 'H' and 'F' however the terminal sends them.
+func readEscape(in *bufio.Reader) (rune, error) {
+	r, _, err := in.ReadRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return 0, err
+	}
+	var param rune
+	for {
+		r, _, err := in.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case r >= '0' && r <= '9':
+			if param == 0 {
+				param = r
+			}
+		case r == ';':
+		case r == '~':
+			switch param {
+			case '1', '7':
+				return 'H', nil
+			case '4', '8':
+				return 'F', nil
+			}
+			return param, nil
+		default:
+			return r, nil
+		}
+	}
+}
+
+// refresh redraws the line and puts the cursor at pos.
+func (e *lineEditor) refresh(prompt string, line []rune, pos int) {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(line))
+	if n := len(line) - pos; n > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", n)
+	}
+}
+
+// browse moves through the history.
+// hist is the index of the history entry that is shown, or len(e.history)
+// for the line that is being edited, which is kept in edited.
+func (e *lineEditor) browse(line []rune, hist int, edited []rune, back bool) ([]rune, int, []rune) {
+	if hist == len(e.history) {
+		edited = append([]rune{}, line...)
+	}
+	switch {
+	case back && hist > 0:
+		hist--
+	case !back && hist < len(e.history):
+		hist++
+	default:
+		return line, hist, edited
+	}
+	if hist == len(e.history) {
+		return append([]rune{}, edited...), hist, edited
+	}
+	return []rune(e.history[hist]), hist, edited
+}
+
+// completeWord completes the word before the cursor.
+// If there is one candidate the word is replaced with it.
+// If there are several the word is extended to their common prefix,
+// and the candidates are listed if the word can not be extended and list is true.
+func (e *lineEditor) completeWord(line []rune, pos int, list bool) ([]rune, int) {
+	if e.complete == nil {
+		return line, pos
+	}
+	start := pos
+	for start > 0 && line[start-1] != ' ' {
+		start--
+	}
+	var (
+		word       = string(line[start:pos])
+		candidates = []string{}
+	)
+	for _, c := range e.complete(string(line[:pos])) {
+		if strings.HasPrefix(c, word) {
+			candidates = append(candidates, c)
+		}
+	}
+	var completion string
+
+	switch len(candidates) {
+	case 0:
+		fmt.Fprint(e.out, "\a")
+		return line, pos
+	case 1:
+		completion = candidates[0] + " "
+	default:
+		completion = commonPrefix(candidates)
+		if completion == word && list {
+			fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+		}
+	}
+	rest := append([]rune(completion), line[pos:]...)
+	line = append(line[:start], rest...)
+	return line, start + len([]rune(completion))
+}
+
+// wordStart returns the index of the start of the word before pos.
+// Spaces between the word and pos are skipped, like Ctrl-W in a terminal.
+func wordStart(line []rune, pos int) int {
+	start := pos
+	for start > 0 && line[start-1] == ' ' {
+		start--
+	}
+	for start > 0 && line[start-1] != ' ' {
+		start--
+	}
+	return start
+}
+
+// commonPrefix returns the longest common prefix of a list of strings.
+func commonPrefix(ss []string) string {
+	prefix := ss[0]
+	for _, s := range ss[1:] {
+		for !strings.HasPrefix(s, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// addHistory adds a line to the history and appends it to the history file.
+// Empty lines and lines that repeat the previous line are not added.
+func (e *lineEditor) addHistory(line string) error {
+	if strings.TrimSpace(line) == "" {
+		return nil
+	}
+	if n := len(e.history); n > 0 && e.history[n-1] == line {
+		return nil
+	}
+	e.history = append(e.history, line)
+
+	if e.historyFile == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(e.historyFile), 0700); err != nil {
+		return errors.Wrap(err, "creating history directory")
+	}
+	f, err := os.OpenFile(e.historyFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return errors.Wrap(err, "opening history file")
+	}
+	if _, err := fmt.Fprintln(f, line); err != nil {
+		_ = f.Close()
+		return errors.Wrap(err, "writing history file")
+	}
+	return errors.Wrap(f.Close(), "closing history file")
+}
+
+// loadHistory loads the history file.
+// If the file has grown past maxHistory lines it is trimmed.
+func (e *lineEditor) loadHistory() error {
+	if e.historyFile == "" {
+		return nil
+	}
+	f, err := os.Open(e.historyFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "opening history file")
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		e.history = append(e.history, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return errors.Wrap(err, "reading history file")
+	}
+	if len(e.history) <= maxHistory {
+		return nil
+	}
+	e.history = e.history[len(e.history)-maxHistory:]
+
+	tmp := e.historyFile + ".tmp"
+	if err := writeLines(tmp, e.history); err != nil {
+		return err
+	}
+	return errors.Wrap(os.Rename(tmp, e.historyFile), "replacing history file")
+}
+
+// writeLines writes lines to a file.
+func writeLines(path string, lines []string) error {
+	data := strings.Join(lines, "\n") + "\n"
+	return errors.Wrap(ioutil.WriteFile(path, []byte(data), 0600), "writing "+path)
+}
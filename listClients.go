@@ -2,78 +2,97 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
-	"time"
+	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
-	"github.com/scgolang/nsm"
-	"github.com/scgolang/osc"
+	"github.com/scgolang/gonzoctl/gonzo"
 )
 
-// ListClients lists the clients currently being managed by a gonzo server.
-func (app *App) ListClients(args []string) error {
-	if err := app.Send(osc.Message{
-		Address: nsm.AddressServerClients,
-	}); err != nil {
-		return errors.Wrap(err, "sending message")
-	}
-	timeout := time.After(app.Timeout)
+// ClientList is the result of the lc command.
+type ClientList []gonzo.ClientInfo
 
-	app.debug("waiting for reply")
+// Rows returns a row with all the details of each client.
+func (l ClientList) Rows() ([]string, [][]string) {
+	rows := make([][]string, len(l))
 
-	select {
-	case <-timeout:
-		return errors.New("timeout")
-	case reply := <-app.replies:
-		app.debug("got reply")
-		if err := app.printClientFrom(reply); err != nil {
+	for i, client := range l {
+		state := "clean"
+		if client.Dirty() {
+			state = "dirty"
+		}
+		caps := make([]string, len(client.Capabilities))
+		for i, cap := range client.Capabilities {
+			caps[i] = string(cap)
+		}
+		status := []string{}
+		for _, flag := range client.Status {
+			if flag != gonzo.StatusDirty {
+				status = append(status, flag)
+			}
+		}
+		rows[i] = []string{
+			client.Name,
+			client.Executable,
+			client.ID,
+			strconv.Itoa(int(client.PID)),
+			state,
+			orDash(strings.Join(caps, ",")),
+			orDash(strings.Join(status, ",")),
+		}
+	}
+	return []string{"NAME", "EXECUTABLE", "ID", "PID", "STATE", "CAPABILITIES", "STATUS"}, rows
+}
+
+// WriteText writes the name of each client.
+func (l ClientList) WriteText(w io.Writer) error {
+	for _, client := range l {
+		if _, err := fmt.Fprintln(w, client.Name); err != nil {
 			return errors.Wrap(err, "printing client")
 		}
 	}
 	return nil
 }
 
-// printClientsFrom prints a client from an OSC reply to /nsm/server/clients
-func (app *App) printClientFrom(msg osc.Message) error {
-	const numClientFields = 6
-
-	if len(msg.Arguments) < 2 {
-		return errors.New("expected two arguments")
-	}
-	addr, err := msg.Arguments[0].ReadString()
+// ListClients lists the clients currently being managed by a gonzo server.
+func (app *App) ListClients(inv *Invocation) error {
+	clients, err := app.clients()
 	if err != nil {
-		return errors.Wrap(err, "reading reply address from osc message")
+		return err
 	}
-	if addr != nsm.AddressServerClients {
-		// TODO: requeue message
+	if inv.Bool("l") && app.Output == OutputText && app.Format == "" {
+		return errors.Wrap(writeResult(os.Stdout, clients, OutputTable, ""), "printing clients")
 	}
-	numClients, err := msg.Arguments[1].ReadInt32()
+	return errors.Wrap(app.print(clients), "printing clients")
+}
+
+// clients gets the clients in the current session.
+func (app *App) clients() (ClientList, error) {
+	clients, err := app.client.Clients(app.ctx)
 	if err != nil {
-		return errors.Wrap(err, "reading number of clients from osc message")
+		return nil, err
 	}
-	if expected, got := (numClients*numClientFields)+2, int32(len(msg.Arguments)); expected != got {
-		return errors.Errorf("expected %d arguments, got %d", expected, got)
-	}
-	for i := int32(0); i < numClients; i++ {
-		j := i * numClientFields
-		clientName, err := msg.Arguments[j+2].ReadString()
-		if err != nil {
-			return errors.Wrap(err, "reading client from osc message")
-		}
-		if _, err := fmt.Println(clientName); err != nil {
-			return errors.Wrap(err, "printing client")
-		}
+	return ClientList(clients), nil
+}
+
+// orDash returns s, or "-" if s is empty.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
 	}
-	return nil
+	return s
 }
 
 func init() {
-	commandUsage["lc"] = func() error {
-		fmt.Fprintf(os.Stderr, "List clients for the current session.\n")
-		fmt.Fprintf(os.Stderr, "\n")
-		fmt.Fprintf(os.Stderr, "Usage:\n")
-		fmt.Fprintf(os.Stderr, "gonzoctl lc\n")
-		fmt.Fprintf(os.Stderr, "\n")
-		return nil
-	}
+	registerCommand(&Command{
+		Name:    "lc",
+		Aliases: []string{"clients"},
+		Summary: "List clients for the current session.",
+		Flags: []Flag{
+			{Name: "l", Default: false, Help: "Show the executable, ID, PID, state, capabilities and status of each client.\nThis is the same as gonzoctl -o table lc."},
+		},
+		Run: (*App).ListClients,
+	})
 }
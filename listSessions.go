@@ -2,88 +2,68 @@ package main
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
-	"time"
+	"io"
 
 	"github.com/pkg/errors"
-	"github.com/scgolang/nsm"
-	"github.com/scgolang/osc"
+	"github.com/scgolang/gonzoctl/gonzo"
 )
 
-// ListSessions lists the sessions managed by a gonzo server.
-func (app *App) ListSessions(args []string) error {
-	if err := app.Send(osc.Message{
-		Address: nsm.AddressServerSessions,
-	}); err != nil {
-		return errors.Wrap(err, "sending message")
-	}
-	timeout := time.After(app.Timeout)
-
-	app.debug("waiting for reply")
+// SessionList is the result of the ls command.
+type SessionList struct {
+	Current  int             `json:"current"`
+	Sessions []gonzo.Session `json:"sessions"`
+}
 
-	select {
-	case <-timeout:
-		return errors.New("timeout")
-	case reply := <-app.replies:
-		app.debug("got reply")
+// Rows returns a row for each session.
+func (l SessionList) Rows() ([]string, [][]string) {
+	rows := make([][]string, len(l.Sessions))
+	for i, session := range l.Sessions {
+		current := ""
+		if session.Current {
+			current = "*"
+		}
+		rows[i] = []string{session.Name, current, session.Path}
+	}
+	return []string{"NAME", "CURRENT", "PATH"}, rows
+}
 
-		if err := app.printSessionFrom(reply); err != nil {
+// WriteText writes the name of each session, marking the current session with a *.
+func (l SessionList) WriteText(w io.Writer) error {
+	for _, session := range l.Sessions {
+		marker := "   "
+		if session.Current {
+			marker = " * "
+		}
+		if _, err := fmt.Fprintln(w, marker+session.Name); err != nil {
 			return errors.Wrap(err, "printing project")
 		}
 	}
 	return nil
 }
 
-// printSessionFrom prints a session from an OSC reply to /nsm/server/list
-func (app *App) printSessionFrom(msg osc.Message) error {
-	const minNumArgs = 3
-
-	if len(msg.Arguments) < minNumArgs {
-		return errors.New("expected two arguments")
-	}
-	addr, err := msg.Arguments[0].ReadString()
-	if err != nil {
-		return errors.Wrap(err, "reading reply address from osc message")
-	}
-	if addr != nsm.AddressServerSessions {
-		// TODO: requeue message
-	}
-	numSessions, err := msg.Arguments[1].ReadInt32()
+// ListSessions lists the sessions managed by a gonzo server.
+func (app *App) ListSessions(inv *Invocation) error {
+	list, err := app.sessions()
 	if err != nil {
-		return errors.Wrap(err, "reading number of sessions from osc message")
+		return err
 	}
-	curridx, err := msg.Arguments[2].ReadInt32()
+	return errors.Wrap(app.print(list), "printing sessions")
+}
+
+// sessions gets the sessions managed by a gonzo server.
+func (app *App) sessions() (SessionList, error) {
+	sessions, current, err := app.client.Sessions(app.ctx)
 	if err != nil {
-		return errors.Wrap(err, "reading current session index from osc message")
-	}
-	if expected, got := numSessions+minNumArgs, int32(len(msg.Arguments)); expected != got {
-		return errors.Errorf("expected %d arguments, got %d", expected, got)
-	}
-	for i := int32(0); i < numSessions; i++ {
-		project, err := msg.Arguments[i+minNumArgs].ReadString()
-		if err != nil {
-			return errors.Wrap(err, "reading project from osc message")
-		}
-		if i == curridx {
-			fmt.Printf(" * ")
-		} else {
-			fmt.Printf("   ")
-		}
-		if _, err := fmt.Println(filepath.Base(project)); err != nil {
-			return errors.Wrap(err, "printing project")
-		}
+		return SessionList{}, err
 	}
-	return nil
+	return SessionList{Current: current, Sessions: sessions}, nil
 }
 
 func init() {
-	commandUsage["ls"] = func() error {
-		fmt.Fprintf(os.Stderr, "List sessions.\n")
-		fmt.Fprintf(os.Stderr, "\n")
-		fmt.Fprintf(os.Stderr, "Usage:\n")
-		fmt.Fprintf(os.Stderr, "gonzoctl ls\n")
-		fmt.Fprintf(os.Stderr, "\n")
-		return nil
-	}
+	registerCommand(&Command{
+		Name:    "ls",
+		Aliases: []string{"list"},
+		Summary: "List sessions.",
+		Run:     (*App).ListSessions,
+	})
 }
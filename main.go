@@ -2,24 +2,23 @@ package main
 
 import (
 	"context"
-	"log"
 )
 
 func main() {
 	config, err := NewConfig()
 	if err != nil {
-		log.Fatal(err)
+		fatal(err, config.Output)
 	}
 
 	app, err := NewApp(context.Background(), config)
 	if err != nil {
-		log.Fatal(err)
+		fatal(err, config.Output)
 	}
 
 	if err := app.Run(); err != nil {
 		if err != context.Canceled && err != context.DeadlineExceeded {
 			_ = app.Close()
-			log.Fatal(err)
+			fatal(err, config.Output)
 		}
 	}
 	_ = app.Close()
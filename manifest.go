@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/gonzoctl/gonzo"
+	"github.com/scgolang/nsm"
+)
+
+// Manifest describes a session and its clients.
+// Manifests are written in YAML or JSON.
+type Manifest struct {
+	Name    string           `json:"name"`
+	Clients []ManifestClient `json:"clients"`
+}
+
+// ManifestClient describes a client of a session.
+type ManifestClient struct {
+	Name       string `json:"name"`
+	Executable string `json:"executable"`
+
+	// GUI is whether the optional GUI of the client is showing.
+	// If it is nil the GUI is left alone.
+	GUI *bool `json:"gui,omitempty"`
+}
+
+// Rows returns a row for each client of the session.
+func (m Manifest) Rows() ([]string, [][]string) {
+	rows := make([][]string, len(m.Clients))
+	for i, client := range m.Clients {
+		gui := "-"
+		if client.GUI != nil {
+			gui = strconv.FormatBool(*client.GUI)
+		}
+		rows[i] = []string{m.Name, client.Name, client.Executable, gui}
+	}
+	return []string{"SESSION", "CLIENT", "EXECUTABLE", "GUI"}, rows
+}
+
+// WriteText writes the manifest as YAML, so that it can be read back by apply.
+func (m Manifest) WriteText(w io.Writer) error {
+	b, err := marshalYAML(m)
+	if err != nil {
+		return errors.Wrap(err, "encoding yaml")
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// validate checks that the manifest can be applied.
+func (m Manifest) validate() error {
+	if m.Name == "" {
+		return errors.New("expected a session name")
+	}
+	seen := map[string]bool{}
+	for i, client := range m.Clients {
+		if client.Name == "" {
+			return errors.Errorf("client %d: expected a name", i+1)
+		}
+		if client.Executable == "" {
+			return errors.Errorf("client %s: expected an executable", client.Name)
+		}
+		if seen[client.Name] {
+			return errors.Errorf("client %s: more than one client with this name", client.Name)
+		}
+		seen[client.Name] = true
+	}
+	return nil
+}
+
+// readManifest reads a manifest from a file, or from stdin if file is -.
+func readManifest(file string) (Manifest, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if file == "-" {
+		file = "stdin"
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(file)
+	}
+	if err != nil {
+		return Manifest{}, errors.Wrap(err, "reading manifest")
+	}
+	m, err := parseManifest(data)
+	if err != nil {
+		return Manifest{}, errors.Wrap(err, "parsing manifest "+file)
+	}
+	return m, nil
+}
+
+// parseManifest parses a YAML or JSON manifest.
+// Unknown fields are an error, so that typos are not silently ignored.
+func parseManifest(data []byte) (Manifest, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) == 0 || trimmed[0] != '{' {
+		converted, err := yamlToJSON(data)
+		if err != nil {
+			return Manifest{}, err
+		}
+		data = converted
+	}
+	var m Manifest
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&m); err != nil {
+		return Manifest{}, err
+	}
+	return m, m.validate()
+}
+
+// sessionManifest returns the manifest of a session and its clients.
+func sessionManifest(name string, clients []gonzo.ClientInfo) Manifest {
+	m := Manifest{Name: name, Clients: []ManifestClient{}}
+	for _, client := range clients {
+		mc := ManifestClient{Name: client.Name, Executable: client.Executable}
+		if client.HasCapability(nsm.CapGUI) {
+			visible := client.GUIVisible()
+			mc.GUI = &visible
+		}
+		m.Clients = append(m.Clients, mc)
+	}
+	return m
+}
+
+// Operations that apply sends to gonzo.
+const (
+	opNew  = "new"
+	opOpen = "open"
+	opAdd  = "add"
+	opShow = "show"
+	opHide = "hide"
+
+	// Differences that apply can not fix, because gonzo can not remove clients.
+	opExtra    = "extra"
+	opMismatch = "mismatch"
+)
+
+// Change is an operation that brings a session closer to its manifest.
+type Change struct {
+	Op         string `json:"op"`
+	Session    string `json:"session,omitempty"`
+	Client     string `json:"client,omitempty"`
+	Executable string `json:"executable,omitempty"`
+
+	// Expected is the executable in the manifest of a client that runs a different one.
+	Expected string `json:"expected,omitempty"`
+}
+
+// String describes the change like a line of a diff.
+func (c Change) String() string {
+	switch c.Op {
+	case opNew:
+		return "+ new session " + c.Session
+	case opOpen:
+		return "~ open session " + c.Session
+	case opAdd:
+		return "+ add client " + c.Client + " " + c.Executable
+	case opExtra:
+		return "- client " + c.Client + " " + c.Executable + " is not in the manifest"
+	case opMismatch:
+		return "! client " + c.Client + " runs " + c.Executable + ", the manifest runs " + c.Expected
+	}
+	return "~ " + c.Op + " gui of client " + c.Client
+}
+
+// applicable returns false if apply can not make the change.
+func (c Change) applicable() bool {
+	return c.Op != opExtra && c.Op != opMismatch
+}
+
+// ChangeList is the result of the diff command.
+type ChangeList []Change
+
+// Rows returns a row for each change.
+func (l ChangeList) Rows() ([]string, [][]string) {
+	rows := make([][]string, len(l))
+	for i, c := range l {
+		rows[i] = []string{c.Op, orDash(c.Session), orDash(c.Client), orDash(c.Executable), orDash(c.Expected)}
+	}
+	return []string{"OP", "SESSION", "CLIENT", "EXECUTABLE", "EXPECTED"}, rows
+}
+
+// WriteText writes a line for each change.
+func (l ChangeList) WriteText(w io.Writer) error {
+	for _, c := range l {
+		if _, err := fmt.Fprintln(w, c.String()); err != nil {
+			return errors.Wrap(err, "printing change")
+		}
+	}
+	return nil
+}
+
+// planSession returns the change that makes the session of the manifest the current session.
+// It returns false if the session is already the current session.
+func planSession(m Manifest, sessions []gonzo.Session, current int) (Change, bool) {
+	session, ok := gonzo.FindSession(sessions, m.Name)
+	if !ok {
+		return Change{Op: opNew, Session: m.Name}, true
+	}
+	if current < 0 || sessions[current] != session {
+		return Change{Op: opOpen, Session: m.Name}, true
+	}
+	return Change{}, false
+}
+
+// planClients returns the changes that make the clients of the current session match the manifest.
+// gonzo can not remove clients, so clients that are not in the manifest, or that run a different
+// executable, are reported with changes that can not be applied.
+func planClients(m Manifest, clients []gonzo.ClientInfo) ChangeList {
+	changes := ChangeList{}
+
+	for _, mc := range m.Clients {
+		client, ok := gonzo.FindClient(clients, mc.Name)
+		switch {
+		case !ok:
+			changes = append(changes, Change{Op: opAdd, Client: mc.Name, Executable: mc.Executable})
+		case client.Executable != mc.Executable:
+			changes = append(changes, Change{Op: opMismatch, Client: client.Name, Executable: client.Executable, Expected: mc.Executable})
+			continue
+		}
+		if mc.GUI == nil {
+			continue
+		}
+		// The GUI of a client without the capability can not be shown or hidden, so it is left alone.
+		// Clients that are added do not have capabilities yet, apply waits for them.
+		if ok && (!client.HasCapability(nsm.CapGUI) || client.GUIVisible() == *mc.GUI) {
+			continue
+		}
+		op := opHide
+		if *mc.GUI {
+			op = opShow
+		}
+		changes = append(changes, Change{Op: op, Client: mc.Name})
+	}
+	for _, client := range clients {
+		if !hasManifestClient(m, client.Name) {
+			changes = append(changes, Change{Op: opExtra, Client: client.Name, Executable: client.Executable})
+		}
+	}
+	return changes
+}
+
+// hasManifestClient returns true if the manifest has a client with the provided name.
+func hasManifestClient(m Manifest, name string) bool {
+	for _, client := range m.Clients {
+		if client.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// planManifest returns the changes that apply would make.
+// If the session is not the current session its clients can not be listed,
+// so only the clients of a new session are compared.
+func (app *App) planManifest(m Manifest) (ChangeList, error) {
+	sessions, current, err := app.client.Sessions(app.ctx)
+	if err != nil {
+		return nil, err
+	}
+	change, ok := planSession(m, sessions, current)
+	if !ok {
+		clients, err := app.client.Clients(app.ctx)
+		if err != nil {
+			return nil, err
+		}
+		return planClients(m, clients), nil
+	}
+	changes := ChangeList{change}
+	if change.Op == opNew {
+		changes = append(changes, planClients(m, nil)...)
+	}
+	return changes, nil
+}
+
+// manifestFlag is the -f flag of the commands that read a manifest.
+var manifestFlag = Flag{Name: "f", Value: "FILE", Default: "-", Help: "Read the manifest from FILE, or from stdin if FILE is - (default)."}
+
+// writeManifestHelp describes manifests in the help of the commands that read them.
+func writeManifestHelp(w io.Writer) {
+	fmt.Fprintf(w, "A manifest describes a session in YAML or JSON. gonzoctl get session writes the\n")
+	fmt.Fprintf(w, "manifest of the current session. gui is optional and only applies to clients\n")
+	fmt.Fprintf(w, "with the %s capability, it is ignored for the other clients.\n", nsm.CapGUI)
+	fmt.Fprintf(w, "\n")
+	fmt.Fprintf(w, "name: show\n")
+	fmt.Fprintf(w, "clients:\n")
+	fmt.Fprintf(w, "  - name: synth\n")
+	fmt.Fprintf(w, "    executable: zynaddsubfx\n")
+	fmt.Fprintf(w, "    gui: true\n")
+	fmt.Fprintf(w, "  - name: drums\n")
+	fmt.Fprintf(w, "    executable: hydrogen\n")
+}
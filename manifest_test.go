@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/scgolang/gonzoctl/gonzo"
+	"github.com/scgolang/nsm"
+)
+
+func TestManifestRoundTrip(t *testing.T) {
+	for _, testcase := range []struct {
+		name    string
+		clients []gonzo.ClientInfo
+	}{
+		{name: "no clients"},
+		{
+			name: "clients",
+			clients: []gonzo.ClientInfo{
+				{Name: "synth", Executable: "zynaddsubfx", Capabilities: nsm.Capabilities{nsm.CapGUI}, Status: []string{gonzo.StatusRunning, gonzo.StatusGUIVisible}},
+				{Name: "drums", Executable: "/usr/bin/hydrogen", Capabilities: nsm.Capabilities{nsm.CapGUI}, Status: []string{gonzo.StatusRunning}},
+				{Name: "seq", Executable: "seq24", Status: []string{gonzo.StatusRunning}},
+			},
+		},
+		{
+			name: "names that need quotes",
+			clients: []gonzo.ClientInfo{
+				{Name: "true", Executable: "a: b"},
+				{Name: "- x", Executable: "#hash"},
+				{Name: "1.5", Executable: " padded "},
+			},
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			// Like gonzoctl get session -o yaml | gonzoctl diff -f -
+			m := sessionManifest("show", testcase.clients)
+
+			var buf bytes.Buffer
+			if err := m.WriteText(&buf); err != nil {
+				t.Fatal(err)
+			}
+			got, err := parseManifest(buf.Bytes())
+			if err != nil {
+				t.Fatalf("parsing %s: %s", buf.String(), err)
+			}
+			if !reflect.DeepEqual(m, got) {
+				t.Fatalf("expected %#v, got %#v", m, got)
+			}
+			if changes := planClients(got, testcase.clients); len(changes) > 0 {
+				t.Fatalf("expected no changes, got %s", changes)
+			}
+		})
+	}
+}
+
+func TestParseManifest(t *testing.T) {
+	for _, testcase := range []struct {
+		name  string
+		data  string
+		fails bool
+	}{
+		{name: "yaml", data: "name: show\nclients:\n  - name: synth\n    executable: zynaddsubfx\n    gui: false\n"},
+		{name: "json", data: `{"name": "show", "clients": [{"name": "synth", "executable": "zynaddsubfx", "gui": false}]}`},
+		{name: "unknown field", data: "name: show\nclient: []\n", fails: true},
+		{name: "no name", data: "clients: []\n", fails: true},
+		{name: "no executable", data: "name: show\nclients:\n  - name: synth\n", fails: true},
+		{name: "same client twice", data: "name: show\nclients:\n  - name: synth\n    executable: a\n  - name: synth\n    executable: b\n", fails: true},
+		{name: "gui is not a bool", data: "name: show\nclients:\n  - name: synth\n    executable: a\n    gui: \"yes\"\n", fails: true},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			m, err := parseManifest([]byte(testcase.data))
+			if testcase.fails {
+				if err == nil {
+					t.Fatalf("expected error, got %#v", m)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			gui := false
+			expected := Manifest{Name: "show", Clients: []ManifestClient{{Name: "synth", Executable: "zynaddsubfx", GUI: &gui}}}
+			if !reflect.DeepEqual(expected, m) {
+				t.Fatalf("expected %#v, got %#v", expected, m)
+			}
+		})
+	}
+}
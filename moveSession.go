@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// MoveSession renames a session.
+func (app *App) MoveSession(inv *Invocation) error {
+	return app.client.MoveSession(app.ctx, inv.Args[0], inv.Args[1])
+}
+
+func init() {
+	registerCommand(&Command{
+		Name:    "mv",
+		Aliases: []string{"move"},
+		Summary: "Rename a session.",
+		Args: []Arg{
+			{Name: "SRC", Help: "The current name of the session.", Complete: (*App).sessionNames},
+			{Name: "DST", Help: "The new name of the session."},
+		},
+		Help: func(w io.Writer) {
+			fmt.Fprintf(w, "If SRC can not be removed after it has been copied to DST,\n")
+			fmt.Fprintf(w, "DST is removed again and SRC is left untouched.\n")
+			fmt.Fprintf(w, "\n")
+			fmt.Fprintf(w, "Example:\n")
+			fmt.Fprintf(w, "gonzoctl mv session1 session2\n")
+		},
+		Run: (*App).MoveSession,
+	})
+}
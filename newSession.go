@@ -1,50 +1,17 @@
 package main
 
-import (
-	"fmt"
-	"os"
-	"time"
-
-	"github.com/pkg/errors"
-	"github.com/scgolang/nsm"
-	"github.com/scgolang/osc"
-)
-
 // NewSession creates a new session.
-func (app *App) NewSession(args []string) error {
-	if expected, got := 1, len(args); expected != got {
-		return errors.Errorf("expected %d arguments, got %d", expected, got)
-	}
-	name := args[0]
-	if err := app.Send(osc.Message{
-		Address: nsm.AddressServerNew,
-		Arguments: osc.Arguments{
-			osc.String(name),
-		},
-	}); err != nil {
-		return errors.Wrap(err, "sending message")
-	}
-
-	app.debug("waiting for reply")
-
-	select {
-	case errReply := <-app.errors:
-		app.debug("got error " + errReply.Error())
-	case reply := <-app.replies:
-		app.debug("got reply for " + reply.Address)
-	case <-time.After(app.Timeout):
-		return errors.New("timeout")
-	}
-	return nil
+func (app *App) NewSession(inv *Invocation) error {
+	return app.client.NewSession(app.ctx, inv.Args[0])
 }
 
 func init() {
-	commandUsage["new"] = func() error {
-		fmt.Fprintf(os.Stderr, "Create a new session.\n")
-		fmt.Fprintf(os.Stderr, "\n")
-		fmt.Fprintf(os.Stderr, "Usage:\n")
-		fmt.Fprintf(os.Stderr, "gonzoctl new NAME\n")
-		fmt.Fprintf(os.Stderr, "\n")
-		return nil
-	}
+	registerCommand(&Command{
+		Name:    "new",
+		Summary: "Create a new session.",
+		Args: []Arg{
+			{Name: "NAME", Help: "The name of the session."},
+		},
+		Run: (*App).NewSession,
+	})
 }
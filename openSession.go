@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// OpenSession opens an existing session.
+func (app *App) OpenSession(inv *Invocation) error {
+	return app.client.OpenSession(app.ctx, inv.Args[0])
+}
+
+func init() {
+	registerCommand(&Command{
+		Name:    "open",
+		Summary: "Open a session.",
+		Args: []Arg{
+			{Name: "NAME", Help: "The name of the session.", Complete: (*App).sessionNames},
+		},
+		Help: func(w io.Writer) {
+			fmt.Fprintf(w, "Example:\n")
+			fmt.Fprintf(w, "gonzoctl open session1\n")
+		},
+		Run: (*App).OpenSession,
+	})
+}
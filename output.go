@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// Output formats.
+const (
+	OutputJSON  = "json"
+	OutputTable = "table"
+	OutputTSV   = "tsv"
+	OutputText  = ""
+	OutputYAML  = "yaml"
+)
+
+// outputFormats are the valid values of the -o option.
+var outputFormats = map[string]bool{
+	OutputJSON:  true,
+	OutputTable: true,
+	OutputTSV:   true,
+	OutputText:  true,
+	OutputYAML:  true,
+}
+
+// Result is the typed result of a command.
+// Every output format is rendered from the same result.
+type Result interface {
+	// Rows returns the column names and rows used by the table and tsv formats.
+	Rows() (header []string, rows [][]string)
+
+	// WriteText writes the default human-readable output.
+	WriteText(w io.Writer) error
+}
+
+// print prints a result in the output format the user asked for.
+func (app *App) print(r Result) error {
+	return writeResult(os.Stdout, r, app.Output, app.Format)
+}
+
+// writeResult writes a result to w.
+// If format is not empty it is used as a text/template and output is ignored.
+func writeResult(w io.Writer, r Result, output, format string) error {
+	if format != "" {
+		tmpl, err := template.New("format").Parse(format)
+		if err != nil {
+			return errors.Wrap(err, "parsing format template")
+		}
+		if err := tmpl.Execute(w, r); err != nil {
+			return errors.Wrap(err, "executing format template")
+		}
+		_, err = fmt.Fprintln(w)
+		return err
+	}
+	switch output {
+	case OutputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return errors.Wrap(enc.Encode(r), "encoding json")
+	case OutputTable:
+		header, rows := r.Rows()
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	case OutputTSV:
+		_, rows := r.Rows()
+		for _, row := range rows {
+			if _, err := fmt.Fprintln(w, strings.Join(row, "\t")); err != nil {
+				return err
+			}
+		}
+		return nil
+	case OutputText:
+		return r.WriteText(w)
+	case OutputYAML:
+		b, err := marshalYAML(r)
+		if err != nil {
+			return errors.Wrap(err, "encoding yaml")
+		}
+		_, err = w.Write(b)
+		return err
+	default:
+		return errors.Errorf("unrecognized output format: %s", output)
+	}
+}
@@ -0,0 +1,14 @@
+package main
+
+// Quit tells gonzo to save the current session and exit.
+func (app *App) Quit(inv *Invocation) error {
+	return app.client.Quit(app.ctx)
+}
+
+func init() {
+	registerCommand(&Command{
+		Name:    "quit",
+		Summary: "Save the current session and stop the gonzo server.",
+		Run:     (*App).Quit,
+	})
+}
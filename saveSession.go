@@ -0,0 +1,14 @@
+package main
+
+// SaveSession saves the current session.
+func (app *App) SaveSession(inv *Invocation) error {
+	return app.client.SaveSession(app.ctx)
+}
+
+func init() {
+	registerCommand(&Command{
+		Name:    "save",
+		Summary: "Save the current session.",
+		Run:     (*App).SaveSession,
+	})
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/gonzoctl/gonzo"
+	"github.com/scgolang/nsm"
+)
+
+// HTTP server settings.
+const (
+	// DefaultHTTPListen is the default address of the HTTP gateway.
+	// It only accepts connections from the same machine.
+	DefaultHTTPListen = "127.0.0.1:8080"
+
+	// maxRequestBody is the largest request body the HTTP gateway reads.
+	maxRequestBody = 1 << 20
+
+	// httpShutdownTimeout is how long the HTTP server waits for requests to finish when it stops.
+	httpShutdownTimeout = 5 * time.Second
+)
+
+// httpStatuses maps the error codes that gonzo can reply with to HTTP status codes.
+var httpStatuses = map[nsm.Code]int{
+	nsm.ErrGeneral:         http.StatusInternalServerError,
+	nsm.ErrIncompatibleAPI: http.StatusNotImplemented,
+	nsm.ErrBlacklisted:     http.StatusForbidden,
+	nsm.ErrLaunchFailed:    http.StatusBadGateway,
+	nsm.ErrNoSuchFile:      http.StatusNotFound,
+	nsm.ErrNoSessionOpen:   http.StatusConflict,
+	nsm.ErrUnsavedChanges:  http.StatusConflict,
+	nsm.ErrNotNow:          http.StatusServiceUnavailable,
+	nsm.ErrBadProject:      http.StatusUnprocessableEntity,
+	nsm.ErrCreateFailed:    http.StatusInternalServerError,
+}
+
+// httpErrorReport is the body of an HTTP error response.
+type httpErrorReport struct {
+	Error   string   `json:"error"`
+	Code    nsm.Code `json:"code,omitempty"`
+	Address string   `json:"address,omitempty"`
+}
+
+// newSessionRequest is the body of POST /sessions.
+type newSessionRequest struct {
+	Name string `json:"name"`
+}
+
+// addClientRequest is the body of POST /clients.
+type addClientRequest struct {
+	Name       string `json:"name"`
+	Executable string `json:"executable"`
+}
+
+// ServeAPI serves the gonzo control API as JSON over HTTP.
+func (app *App) ServeAPI(inv *Invocation) error {
+	app.keepServing()
+
+	return app.listenAndServe(inv.String("listen"), app.apiHandler(splitList(inv.String("allow-origin"))))
+}
+
+// listenAndServe serves HTTP on the listen address until the app is canceled.
+func (app *App) listenAndServe(listen string, handler http.Handler) error {
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		return errors.Wrap(err, "listening for HTTP")
+	}
+	server := &http.Server{Handler: handler}
+
+	go func() {
+		<-app.ctx.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+	}()
+	fmt.Fprintf(os.Stderr, "listening on http://%s\n", ln.Addr())
+
+	if err := server.Serve(ln); err != http.ErrServerClosed {
+		return errors.Wrap(err, "serving HTTP")
+	}
+	return app.ctx.Err()
+}
+
+// apiHandler returns the handler for the HTTP API and the WebSocket bridge.
+// allowedOrigins are the origins other than the API's own that can change sessions
+// and use the WebSocket bridge.
+func (app *App) apiHandler(allowedOrigins []string) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/sessions", app.checkChange(app.handleSessions, allowedOrigins))
+	mux.Handle("/sessions/", app.checkChange(app.handleSession, allowedOrigins))
+	mux.Handle("/clients", app.checkChange(app.handleClients, allowedOrigins))
+	mux.Handle("/clients/", app.checkChange(app.handleClient, allowedOrigins))
+	mux.Handle("/ws", app.websocketHandler(app.runEventHub(), allowedOrigins))
+	return mux
+}
+
+// checkChange rejects requests that change state unless they come from an allowed origin,
+// and rejects request bodies that are not JSON.
+// Browsers send forms from any page without asking first, but not JSON,
+// so together these keep other web pages from controlling gonzo.
+func (app *App) checkChange(handler http.HandlerFunc, allowedOrigins []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			handler(w, r)
+			return
+		}
+		if err := checkRequestOrigin(r, allowedOrigins); err != nil {
+			app.debugf("%s %s: %s", r.Method, r.URL.Path, err)
+			app.writeJSON(w, http.StatusForbidden, httpErrorReport{Error: err.Error()})
+			return
+		}
+		if r.Method == http.MethodPost {
+			if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+				app.writeJSON(w, http.StatusUnsupportedMediaType, httpErrorReport{Error: "expected Content-Type application/json"})
+				return
+			}
+		}
+		handler(w, r)
+	})
+}
+
+// checkRequestOrigin checks the Origin header of an HTTP request
+// like the origin of a WebSocket connection is checked.
+func checkRequestOrigin(r *http.Request, allowedOrigins []string) error {
+	header := r.Header.Get("Origin")
+	if header == "" {
+		return nil
+	}
+	origin, err := url.Parse(header)
+	if err != nil {
+		return errors.Wrap(err, "parsing origin")
+	}
+	if !originAllowed(origin, r.Host, allowedOrigins) {
+		return errors.Errorf("origin %s is not allowed", header)
+	}
+	return nil
+}
+
+// splitList splits a comma-separated list, ignoring empty items.
+func splitList(s string) []string {
+	items := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// handleSessions handles GET and POST /sessions.
+func (app *App) handleSessions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		sessions, current, err := app.client.Sessions(r.Context())
+		if err != nil {
+			app.writeHTTPError(w, r, err)
+			return
+		}
+		app.writeJSON(w, http.StatusOK, SessionList{Current: current, Sessions: sessions})
+	case http.MethodPost:
+		var req newSessionRequest
+		if err := readJSON(r, &req); err != nil {
+			app.writeHTTPError(w, r, err)
+			return
+		}
+		if req.Name == "" {
+			app.writeHTTPError(w, r, usageErrorf("expected session name"))
+			return
+		}
+		if err := app.client.NewSession(r.Context(), req.Name); err != nil {
+			app.writeHTTPError(w, r, err)
+			return
+		}
+		app.writeJSON(w, http.StatusCreated, req)
+	default:
+		allowMethods(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+// handleSession handles DELETE /sessions/{name}.
+func (app *App) handleSession(w http.ResponseWriter, r *http.Request) {
+	name, rest := splitPath(r.URL.Path, "/sessions/")
+	if name == "" || rest != "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		allowMethods(w, http.MethodDelete)
+		return
+	}
+	if err := app.client.RemoveSession(r.Context(), name); err != nil {
+		app.writeHTTPError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleClients handles GET and POST /clients.
+func (app *App) handleClients(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		clients, err := app.client.Clients(r.Context())
+		if err != nil {
+			app.writeHTTPError(w, r, err)
+			return
+		}
+		app.writeJSON(w, http.StatusOK, ClientList(clients))
+	case http.MethodPost:
+		var req addClientRequest
+		if err := readJSON(r, &req); err != nil {
+			app.writeHTTPError(w, r, err)
+			return
+		}
+		if req.Name == "" || req.Executable == "" {
+			app.writeHTTPError(w, r, usageErrorf("expected client name and executable"))
+			return
+		}
+		if err := app.client.Add(r.Context(), req.Name, req.Executable); err != nil {
+			app.writeHTTPError(w, r, err)
+			return
+		}
+		app.writeJSON(w, http.StatusCreated, req)
+	default:
+		allowMethods(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+// handleClient handles GET /clients/{name}/logs.
+func (app *App) handleClient(w http.ResponseWriter, r *http.Request) {
+	name, rest := splitPath(r.URL.Path, "/clients/")
+	if name == "" || rest != "logs" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		allowMethods(w, http.MethodGet)
+		return
+	}
+	stream := r.URL.Query().Get("stream")
+	if stream == "" {
+		stream = gonzo.StreamStderr
+	}
+	if !logOutputOptions[stream] {
+		app.writeHTTPError(w, r, usageErrorf("expected stream to be either stderr or stdout"))
+		return
+	}
+	lines, err := app.client.Logs(r.Context(), name, stream)
+	if err != nil {
+		app.writeHTTPError(w, r, err)
+		return
+	}
+	app.writeJSON(w, http.StatusOK, Logs{Client: name, Stream: stream, Lines: lines})
+}
+
+// splitPath removes prefix from path and splits the rest into the first element and the rest.
+func splitPath(path, prefix string) (string, string) {
+	path = strings.TrimPrefix(path, prefix)
+	if i := strings.Index(path, "/"); i >= 0 {
+		return path[:i], path[i+1:]
+	}
+	return path, ""
+}
+
+// readJSON decodes the JSON body of a request.
+func readJSON(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxRequestBody))
+	if err := dec.Decode(v); err != nil {
+		return usageErrorf("decoding request body: %s", err)
+	}
+	return nil
+}
+
+// allowMethods responds that the request method is not allowed.
+func allowMethods(w http.ResponseWriter, methods ...string) {
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+}
+
+// writeJSON writes a JSON response.
+func (app *App) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		app.debugf("writing HTTP response: %s", err)
+	}
+}
+
+// writeHTTPError writes an error response with the HTTP status for the error.
+func (app *App) writeHTTPError(w http.ResponseWriter, r *http.Request, err error) {
+	app.debugf("%s %s: %s", r.Method, r.URL.Path, err)
+
+	report := httpErrorReport{Error: err.Error()}
+	if e, ok := errors.Cause(err).(gonzo.Error); ok {
+		report.Code, report.Address = e.Code(), e.Address
+	}
+	app.writeJSON(w, httpStatus(err), report)
+}
+
+// httpStatus returns the HTTP status code for an error.
+func httpStatus(err error) int {
+	cause := errors.Cause(err)
+	if cause == gonzo.ErrTimeout {
+		return http.StatusGatewayTimeout
+	}
+	switch e := cause.(type) {
+	case gonzo.Error:
+		if status, ok := httpStatuses[e.Code()]; ok {
+			return status
+		}
+	case UsageError:
+		return http.StatusBadRequest
+	case *net.OpError, *net.DNSError, *net.AddrError:
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+func init() {
+	registerCommand(&Command{
+		Name:    "serve-http",
+		Summary: "Serve the gonzo control API as JSON over HTTP.",
+		Flags: []Flag{
+			{Name: "listen", Value: "ADDRESS", Default: DefaultHTTPListen, Help: fmt.Sprintf("Address to listen on (default %s).", DefaultHTTPListen)},
+			{Name: "allow-origin", Value: "ORIGINS", Default: "", Help: "Comma-separated origins of web pages, other than the server itself,\nthat can change sessions and connect to the WebSocket bridge,\ne.g. http://tablet:3000. Use * to allow any origin."},
+		},
+		Help: func(w io.Writer) {
+			fmt.Fprintf(w, "Routes:\n")
+			fmt.Fprintf(w, "GET    /sessions                       List sessions.\n")
+			fmt.Fprintf(w, "POST   /sessions                       Create a session, e.g. {\"name\": \"show\"}.\n")
+			fmt.Fprintf(w, "DELETE /sessions/NAME                  Remove a session.\n")
+			fmt.Fprintf(w, "GET    /clients                        List clients of the current session.\n")
+			fmt.Fprintf(w, "POST   /clients                        Add a client, e.g. {\"name\": \"synth\", \"executable\": \"zynaddsubfx\"}.\n")
+			fmt.Fprintf(w, "GET    /clients/NAME/logs?stream=S     Get the logs of a client, S is stderr (default) or stdout.\n")
+			fmt.Fprintf(w, "GET    /ws                             WebSocket bridge for events and commands, see below.\n")
+			fmt.Fprintf(w, "\n")
+			fmt.Fprintf(w, "POST bodies must be sent with Content-Type application/json, or the status is 415.\n")
+			fmt.Fprintf(w, "Requests that change sessions from a web page of an origin that is not allowed get 403.\n")
+			fmt.Fprintf(w, "\n")
+			fmt.Fprintf(w, "Errors are returned as {\"error\": ..., \"code\": ...} where code is the gonzo error code.\n")
+			fmt.Fprintf(w, "The HTTP status follows the gonzo error code, e.g. 404 for a session that does not exist,\n")
+			fmt.Fprintf(w, "409 if no session is open or there are unsaved changes, and 504 if gonzo does not reply.\n")
+			fmt.Fprintf(w, "\n")
+			fmt.Fprintf(w, "WebSocket bridge:\n")
+			fmt.Fprintf(w, "Every event from gonzo is sent as {\"type\": \"event\", \"event\": {...}}, with the same fields\n")
+			fmt.Fprintf(w, "as gonzoctl -o json watch. {\"type\": \"disconnected\"} and {\"type\": \"reconnected\"} are sent\n")
+			fmt.Fprintf(w, "when the connection to gonzo is lost and when it comes back.\n")
+			fmt.Fprintf(w, "\n")
+			fmt.Fprintf(w, "Commands are sent as {\"id\": \"1\", \"command\": \"open\", \"args\": [\"show\"]}.\n")
+			fmt.Fprintf(w, "The reply is {\"type\": \"reply\", \"id\": \"1\", \"result\": ...} or\n")
+			fmt.Fprintf(w, "{\"type\": \"error\", \"id\": \"1\", \"error\": ..., \"code\": ...}.\n")
+			fmt.Fprintf(w, "Commands are named after the /nsm/server requests they make, which can also be used as names:\n")
+			fmt.Fprintf(w, "%s\n", strings.Join(sortedBridgeCommands(), ", "))
+		},
+		Run: (*App).ServeAPI,
+	})
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Shell settings.
+const (
+	shellPrompt = "gonzo> "
+
+	// completionTimeout is how long tab completion waits for gonzo.
+	completionTimeout = time.Second
+)
+
+// Shell reads commands from stdin and runs them over the app's connection to gonzo.
+// If stdin is a terminal the commands can be edited and completed, and are saved in the history.
+func (app *App) Shell(inv *Invocation) error {
+	app.keepServing()
+
+	if !isTerminal(int(os.Stdin.Fd())) {
+		return newScript(app, "stdin").run(os.Stdin)
+	}
+	sh := newScript(app, "shell")
+	sh.interactive, sh.errexit = true, false
+
+	editor, err := newLineEditor(historyFilePath(), app.completeShell)
+	if err != nil {
+		return err
+	}
+	for {
+		line, err := editor.readLine(shellPrompt)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := editor.addHistory(strings.TrimSpace(line)); err != nil {
+			app.debugf("could not save history: %s", err)
+		}
+		if exit, err := sh.line(line); exit {
+			return nil
+		} else if err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// shellCommand runs a single command.
+// The command gets its own context, which is canceled by Ctrl-C, so that
+// commands like watch and logs -f can be stopped without leaving the shell.
+func (app *App) shellCommand(name string, args []string) error {
+	ctx, cancel := context.WithCancel(app.ctx)
+	defer cancel()
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	go func() {
+		select {
+		case <-interrupts:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	cmdApp := *app
+	cmdApp.ctx = ctx
+
+	err := cmdApp.runCommand(append([]string{name}, args...))
+	if err == context.Canceled && app.ctx.Err() == nil {
+		return nil
+	}
+	return err
+}
+
+// completeShell returns the candidates for the last word of a line of shell input.
+func (app *App) completeShell(line string) []string {
+	words := strings.Fields(line)
+	if len(words) == 0 || strings.HasSuffix(line, " ") {
+		words = append(words, "")
+	}
+	if len(words) == 1 {
+		return app.shellCommandNames()
+	}
+	return app.completeCommand(words, nil)
+}
+
+// commandNames returns the names of the commands that are not hidden.
+func (app *App) commandNames() []string {
+	names := []string{}
+	for _, cmd := range visibleCommands() {
+		names = append(names, cmd.Name)
+	}
+	return names
+}
+
+// shellCommandNames returns the names of the commands that can be run in the shell.
+func (app *App) shellCommandNames() []string {
+	names := []string{"exit"}
+	for _, name := range app.commandNames() {
+		if name != "shell" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sessionNames returns the names of the sessions on the gonzo server.
+func (app *App) sessionNames() []string {
+	return app.cachedNames("sessions", app.fetchSessionNames)
+}
+
+// fetchSessionNames gets the names of the sessions from the gonzo server.
+func (app *App) fetchSessionNames() []string {
+	if err := app.dial(); err != nil {
+		app.debugf("could not complete session names: %s", err)
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(app.ctx, completionTimeout)
+	defer cancel()
+
+	sessions, _, err := app.client.Sessions(ctx)
+	if err != nil {
+		app.debugf("could not complete session names: %s", err)
+		return nil
+	}
+	names := make([]string, len(sessions))
+	for i, session := range sessions {
+		names[i] = session.Name
+	}
+	return names
+}
+
+// clientNames returns the names of the clients in the current session.
+func (app *App) clientNames() []string {
+	return app.cachedNames("clients", app.fetchClientNames)
+}
+
+// fetchClientNames gets the names of the clients in the current session from the gonzo server.
+func (app *App) fetchClientNames() []string {
+	if err := app.dial(); err != nil {
+		app.debugf("could not complete client names: %s", err)
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(app.ctx, completionTimeout)
+	defer cancel()
+
+	clients, err := app.client.Clients(ctx)
+	if err != nil {
+		app.debugf("could not complete client names: %s", err)
+		return nil
+	}
+	names := make([]string, len(clients))
+	for i, client := range clients {
+		names[i] = client.Name
+	}
+	return names
+}
+
+// contextNames returns the names of the contexts in the config file.
+func contextNames() []string {
+	file, err := LoadConfigFile(configFilePath())
+	if err != nil {
+		return nil
+	}
+	names := []string{}
+	for name := range file.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sortedKeys returns the keys of a map in order.
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// historyFilePath returns the path of the shell's history file,
+// which is next to the config file.
+func historyFilePath() string {
+	return filepath.Join(filepath.Dir(configFilePath()), "history")
+}
+
+// splitWords splits a line into words like a shell does.
+// Words are separated by spaces and can be quoted with single or double quotes.
+// A backslash escapes the next character outside of single quotes.
+// A word that starts with # starts a comment, which goes on to the end of the line.
+// If lookup is not nil, $NAME and ${NAME} are replaced by the value of the variable
+// outside of single quotes.
+func splitWords(line string, lookup func(name string) (string, error)) ([]string, error) {
+	var (
+		words   = []string{}
+		word    strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+		runes   = []rune(line)
+	)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case r == '$' && quote != '\'' && lookup != nil:
+			name, n, err := variableName(runes[i+1:])
+			if err != nil {
+				return nil, err
+			}
+			if n == 0 {
+				word.WriteRune(r)
+				inWord = true
+				continue
+			}
+			value, err := lookup(name)
+			if err != nil {
+				return nil, err
+			}
+			word.WriteString(value)
+			inWord, i = true, i+n
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == '#' && !inWord:
+			i = len(runes)
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.Errorf("unterminated %c quote", quote)
+	}
+	if escaped {
+		return nil, errors.New("line ends with a backslash")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// variableName reads the name of a variable after a $, which is either NAME or {NAME}.
+// It returns the name and the number of runes it read, which is 0 if there is no name.
+func variableName(runes []rune) (string, int, error) {
+	if len(runes) > 0 && runes[0] == '{' {
+		for i, r := range runes[1:] {
+			if r == '}' {
+				name := string(runes[1 : i+1])
+				if !isVariableName(name) {
+					return "", 0, errors.Errorf("bad variable name: ${%s}", name)
+				}
+				return name, i + 2, nil
+			}
+		}
+		return "", 0, errors.New("unterminated ${")
+	}
+	n := 0
+	for n < len(runes) && isVariableRune(runes[n], n == 0) {
+		n++
+	}
+	return string(runes[:n]), n, nil
+}
+
+func init() {
+	registerCommand(&Command{
+		Name:    "shell",
+		Summary: "Run commands interactively over a single connection to gonzo.",
+		Help: func(w io.Writer) {
+			fmt.Fprintf(w, "The shell accepts the same commands as gonzoctl, e.g. ls, lc, add and logs.\n")
+			fmt.Fprintf(w, "Type exit or press Ctrl-D to leave the shell. Ctrl-C stops a running command.\n")
+			fmt.Fprintf(w, "\n")
+			fmt.Fprintf(w, "Keys:\n")
+			fmt.Fprintf(w, "Tab               Complete commands, session names and client names.\n")
+			fmt.Fprintf(w, "Up, Down          Browse the history, which is saved in %s.\n", historyFilePath())
+			fmt.Fprintf(w, "Left, Right       Move the cursor.\n")
+			fmt.Fprintf(w, "Ctrl-A, Ctrl-E    Move to the start or end of the line.\n")
+			fmt.Fprintf(w, "Ctrl-K, Ctrl-U    Delete to the end or start of the line.\n")
+			fmt.Fprintf(w, "Ctrl-W            Delete the word before the cursor.\n")
+			fmt.Fprintf(w, "Ctrl-L            Clear the screen.\n")
+			fmt.Fprintf(w, "\n")
+			fmt.Fprintf(w, "If stdin is not a terminal, commands are read one per line\n")
+			fmt.Fprintf(w, "and the shell stops at the first command that fails.\n")
+			fmt.Fprintf(w, "Comments, variables and set work like in scripts, see gonzoctl help exec.\n")
+		},
+		Run: (*App).Shell,
+	})
+}
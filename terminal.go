@@ -0,0 +1,90 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// termState is the state of a terminal before it was put in raw mode.
+type termState struct {
+	termios syscall.Termios
+}
+
+// isTerminal returns true if fd refers to a terminal.
+func isTerminal(fd int) bool {
+	var termios syscall.Termios
+	return ioctl(fd, ioctlGetTermios, &termios) == nil
+}
+
+// makeRaw puts a terminal in raw mode, so that we get each key as it is pressed
+// and the terminal does not echo them.
+// Output processing is left on, so "\n" still starts a new line.
+func makeRaw(fd int) (*termState, error) {
+	var state termState
+	if err := ioctl(fd, ioctlGetTermios, &state.termios); err != nil {
+		return nil, err
+	}
+	raw := state.termios
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+
+	if err := ioctl(fd, ioctlSetTermios, &raw); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// setReadTimeout makes reads from a terminal in raw mode return after timeout
+// with no data if no key is pressed, rounded to tenths of a second.
+// The timeout is reset by restoreTerminal.
+func setReadTimeout(fd int, timeout time.Duration) error {
+	var termios syscall.Termios
+	if err := ioctl(fd, ioctlGetTermios, &termios); err != nil {
+		return err
+	}
+	termios.Cc[syscall.VMIN] = 0
+	termios.Cc[syscall.VTIME] = uint8((timeout + 99*time.Millisecond) / (100 * time.Millisecond))
+
+	return ioctl(fd, ioctlSetTermios, &termios)
+}
+
+// restoreTerminal restores the state of a terminal that was put in raw mode.
+func restoreTerminal(fd int, state *termState) error {
+	return ioctl(fd, ioctlSetTermios, &state.termios)
+}
+
+// terminalSize returns the width and height of a terminal.
+func terminalSize(fd int) (int, int, error) {
+	var ws struct {
+		Row, Col, Xpixel, Ypixel uint16
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&ws)))
+	if errno != 0 {
+		return 0, 0, errno
+	}
+	return int(ws.Col), int(ws.Row), nil
+}
+
+// notifyResize relays the signal that is sent when the terminal is resized to c.
+func notifyResize(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGWINCH)
+}
+
+// ioctl gets or sets the attributes of a terminal.
+func ioctl(fd int, req uintptr, termios *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req, uintptr(unsafe.Pointer(termios)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
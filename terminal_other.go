@@ -0,0 +1,43 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// termState is the state of a terminal before it was put in raw mode.
+type termState struct{}
+
+// isTerminal always returns false, so the shell reads plain lines.
+func isTerminal(fd int) bool {
+	return false
+}
+
+// makeRaw is not supported on this platform.
+func makeRaw(fd int) (*termState, error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
+
+// setReadTimeout is not supported on this platform.
+func setReadTimeout(fd int, timeout time.Duration) error {
+	return errors.New("terminal read timeouts are not supported on this platform")
+}
+
+// terminalSize is not supported on this platform.
+func terminalSize(fd int) (int, int, error) {
+	return 0, 0, errors.New("terminal size is not supported on this platform")
+}
+
+// notifyResize does nothing on this platform.
+func notifyResize(c chan<- os.Signal) {
+}
+
+// restoreTerminal is not supported on this platform.
+func restoreTerminal(fd int, state *termState) error {
+	return nil
+}
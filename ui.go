@@ -0,0 +1,474 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/gonzoctl/gonzo"
+	"github.com/scgolang/nsm"
+)
+
+// uiPollInterval is how often the dashboard polls gonzo.
+const uiPollInterval = time.Second
+
+// uiKeyTimeout is how long a read from the terminal waits for a key
+// before it checks whether the dashboard has quit.
+const uiKeyTimeout = 100 * time.Millisecond
+
+// errKeysStopped is returned by keyReader after the dashboard quits.
+var errKeysStopped = errors.New("stopped reading keys")
+
+// Keys that the dashboard handles in addition to the line editor's keys.
+const (
+	keyUp = 0xE000 + iota // private use runes, which terminals never send
+	keyDown
+)
+
+// Dashboard panes that can have the focus.
+const (
+	paneSessions = iota
+	paneClients
+)
+
+// uiState is everything the dashboard shows.
+// It is only touched by the goroutine that runs the dashboard's event loop.
+type uiState struct {
+	sessions []gonzo.Session
+	current  int
+	clients  []gonzo.ClientInfo
+
+	// progress and messages come from events.
+	progress map[string]float32
+	messages map[string]string
+
+	logClient string
+	logStream string
+	logs      []string
+
+	focus         int
+	selectedIndex [2]int
+
+	// input is the text typed at the prompt, which is showing if prompt is not empty.
+	prompt string
+	input  []rune
+
+	// notice is shown in the status line until the next key is pressed.
+	notice string
+}
+
+// uiSnapshot is the result of polling gonzo.
+type uiSnapshot struct {
+	sessions  []gonzo.Session
+	current   int
+	clients   []gonzo.ClientInfo
+	logClient string
+	logStream string
+	logs      []string
+	err       error
+}
+
+// UI runs a full-screen dashboard that shows sessions, clients and logs.
+func (app *App) UI(inv *Invocation) error {
+	fd := int(os.Stdin.Fd())
+	if !isTerminal(fd) {
+		return usageErrorf("ui needs a terminal")
+	}
+	state, err := makeRaw(fd)
+	if err != nil {
+		return errors.Wrap(err, "setting terminal to raw mode")
+	}
+	defer func() { _ = restoreTerminal(fd, state) }()
+
+	// Reads time out so that the key reader can stop when the dashboard quits,
+	// otherwise it would take the next key from whoever reads the terminal after us, e.g. the shell.
+	if err := setReadTimeout(fd, uiKeyTimeout); err != nil {
+		return errors.Wrap(err, "setting terminal read timeout")
+	}
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+	app.keepServing()
+
+	return app.runUI(fd)
+}
+
+// runUI runs the dashboard's event loop until q is pressed or the app is canceled.
+func (app *App) runUI(fd int) error {
+	var (
+		keys      = make(chan rune)
+		keyErrs   = make(chan error, 1)
+		keysDone  = make(chan struct{})
+		stop      = make(chan struct{})
+		snapshots = make(chan uiSnapshot, 1)
+		results   = make(chan error, 1)
+		done      = make(chan struct{})
+		resize    = make(chan os.Signal, 1)
+		poll      = time.NewTicker(uiPollInterval)
+		renew     = time.NewTicker(watchRenewInterval)
+		polling   bool
+		ui        = &uiState{
+			current:   -1,
+			progress:  map[string]float32{},
+			messages:  map[string]string{},
+			logStream: gonzo.StreamStderr,
+		}
+	)
+	defer poll.Stop()
+	defer renew.Stop()
+	defer close(done)
+
+	// Requests to gonzo run in the background, and they can outlive the dashboard.
+	report := func(err error) {
+		select {
+		case results <- err:
+		case <-done:
+		}
+	}
+
+	notifyResize(resize)
+
+	go func() {
+		defer close(keysDone)
+		readKeys(bufio.NewReader(keyReader{f: os.Stdin, stop: stop}), keys, keyErrs, stop)
+	}()
+	defer func() {
+		close(stop)
+		<-keysDone
+	}()
+
+	startPoll := func() {
+		if polling {
+			return
+		}
+		polling = true
+		var (
+			client, _ = ui.selectedClient()
+			stream    = ui.logStream
+		)
+		go func() { snapshots <- app.pollUI(client.Name, stream) }()
+	}
+	go func() { report(app.client.Subscribe(app.ctx)) }()
+	startPoll()
+
+	for {
+		app.drawUI(fd, ui)
+
+		select {
+		case <-app.ctx.Done():
+			return app.ctx.Err()
+		case err := <-keyErrs:
+			return errors.Wrap(err, "reading keys")
+		case <-resize:
+		case <-poll.C:
+			startPoll()
+		case <-renew.C:
+			go func() { report(app.client.Subscribe(app.ctx)) }()
+		case snap := <-snapshots:
+			polling = false
+			ui.update(snap)
+		case ev := <-app.client.Events():
+			ui.handleEvent(ev)
+		case err := <-results:
+			if err != nil {
+				ui.notice = err.Error()
+			}
+			startPoll()
+		case key := <-keys:
+			if ui.prompt != "" {
+				ui.editInput(key, func(input string) { go app.uiInput(input, report) })
+				continue
+			}
+			ui.notice = ""
+			if quit := app.uiKey(ui, key, report); quit {
+				return nil
+			}
+			startPoll()
+		}
+	}
+}
+
+// keyReader reads from a terminal with a read timeout until stop is closed.
+// Reads that time out are retried, so they look like blocking reads to the caller.
+type keyReader struct {
+	f    *os.File
+	stop <-chan struct{}
+}
+
+// Read reads from the terminal, or returns errKeysStopped once stop is closed.
+func (r keyReader) Read(p []byte) (int, error) {
+	for {
+		select {
+		case <-r.stop:
+			return 0, errKeysStopped
+		default:
+		}
+		n, err := r.f.Read(p)
+		if n > 0 || err != io.EOF {
+			return n, err
+		}
+	}
+}
+
+// readKeys reads keys from the terminal and sends them to keys until stop is closed.
+// Arrow keys are translated to keyUp and keyDown.
+func readKeys(in *bufio.Reader, keys chan<- rune, errs chan<- error, stop <-chan struct{}) {
+	for {
+		r, _, err := in.ReadRune()
+		if err == errKeysStopped {
+			return
+		}
+		if err != nil {
+			errs <- err
+			return
+		}
+		if r == keyEscape {
+			seq, err := readEscape(in)
+			if err == errKeysStopped {
+				return
+			}
+			if err != nil {
+				errs <- err
+				return
+			}
+			switch seq {
+			case 'A':
+				r = keyUp
+			case 'B':
+				r = keyDown
+			default:
+				continue
+			}
+		}
+		select {
+		case keys <- r:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// uiKey handles a key that was pressed in the dashboard.
+// Requests to gonzo run in the background and pass their error to report.
+// It returns true if the dashboard should quit.
+func (app *App) uiKey(ui *uiState, key rune, report func(error)) bool {
+	background := func(f func(ctx context.Context) error) {
+		go func() { report(f(app.ctx)) }()
+	}
+	switch key {
+	case 'q', keyCtrlC:
+		return true
+	case keyTab:
+		ui.focus = (ui.focus + 1) % 2
+	case keyUp, 'k':
+		ui.move(-1)
+	case keyDown, 'j':
+		ui.move(1)
+	case 'o', keyEnter:
+		if ui.focus != paneSessions || len(ui.sessions) == 0 {
+			break
+		}
+		name := ui.sessions[ui.selected(paneSessions)].Name
+		ui.notice = "opening " + name
+		background(func(ctx context.Context) error { return app.client.OpenSession(ctx, name) })
+	case 's':
+		ui.notice = "saving"
+		background(app.client.SaveSession)
+	case 'c':
+		ui.notice = "closing"
+		background(app.client.CloseSession)
+	case 'a':
+		ui.prompt = "add client (NAME EXECUTABLE): "
+		ui.input = nil
+	case 'g', 'h':
+		client, ok := ui.selectedClient()
+		if !ok {
+			break
+		}
+		if !client.HasCapability(nsm.CapGUI) {
+			ui.notice = fmt.Sprintf("%s does not have the %s capability", client.Name, nsm.CapGUI)
+			break
+		}
+		show := key == 'g'
+		background(func(ctx context.Context) error { return app.client.SetGUI(ctx, client.Name, show) })
+	case 'l':
+		if ui.logStream == gonzo.StreamStderr {
+			ui.logStream = gonzo.StreamStdout
+		} else {
+			ui.logStream = gonzo.StreamStderr
+		}
+		ui.logs = nil
+	}
+	return false
+}
+
+// uiInput adds the client that was entered at the add client prompt
+// and passes the error to report.
+func (app *App) uiInput(input string, report func(error)) {
+	words, err := splitWords(input, nil)
+	if err != nil {
+		report(err)
+		return
+	}
+	if expected, got := 2, len(words); expected != got {
+		report(errors.Errorf("expected NAME EXECUTABLE, got %q", input))
+		return
+	}
+	report(app.client.Add(app.ctx, words[0], words[1]))
+}
+
+// pollUI gets the sessions, the clients and the logs of a client from gonzo.
+func (app *App) pollUI(logClient, logStream string) uiSnapshot {
+	snap := uiSnapshot{current: -1, logClient: logClient, logStream: logStream}
+
+	snap.sessions, snap.current, snap.err = app.client.Sessions(app.ctx)
+	if snap.err != nil || snap.current < 0 {
+		return snap
+	}
+	if snap.clients, snap.err = app.client.Clients(app.ctx); snap.err != nil {
+		return snap
+	}
+	if logClient == "" {
+		return snap
+	}
+	snap.logs, snap.err = app.client.Logs(app.ctx, logClient, logStream)
+	return snap
+}
+
+// update applies the result of polling gonzo.
+func (ui *uiState) update(snap uiSnapshot) {
+	if snap.err != nil {
+		ui.notice = snap.err.Error()
+		return
+	}
+	ui.sessions, ui.current, ui.clients = snap.sessions, snap.current, snap.clients
+
+	if client, ok := ui.selectedClient(); ok && client.Name == snap.logClient && ui.logStream == snap.logStream {
+		ui.logClient, ui.logs = snap.logClient, snap.logs
+	} else if !ok {
+		ui.logClient, ui.logs = "", nil
+	}
+}
+
+// handleEvent applies an event from gonzo.
+// Dirty and GUI flags are updated right away instead of waiting for the next poll.
+func (ui *uiState) handleEvent(ev gonzo.Event) {
+	for i := range ui.clients {
+		client := &ui.clients[i]
+		if client.Name != ev.Client {
+			continue
+		}
+		switch ev.Type {
+		case gonzo.EventDirty, gonzo.EventClean:
+			setStatusFlag(client, gonzo.StatusDirty, ev.Type == gonzo.EventDirty)
+		case gonzo.EventGUIShowing, gonzo.EventGUIHidden:
+			setStatusFlag(client, gonzo.StatusGUIVisible, ev.Type == gonzo.EventGUIShowing)
+		}
+	}
+	switch {
+	case ev.Progress != nil:
+		ui.progress[ev.Client] = *ev.Progress
+	case ev.Type == gonzo.EventStatus:
+		ui.messages[ev.Client] = ev.Message
+	case ev.Type == gonzo.EventExited, ev.Type == gonzo.EventLaunched:
+		ui.messages[ev.Client] = ev.Type + " " + ev.Detail()
+	}
+}
+
+// editInput handles a key that was pressed while the prompt is showing.
+func (ui *uiState) editInput(key rune, submit func(input string)) {
+	switch key {
+	case keyEnter, '\n':
+		submit(string(ui.input))
+		ui.prompt, ui.input = "", nil
+	case keyCtrlC:
+		ui.prompt, ui.input = "", nil
+	case keyBackspace, keyCtrlH:
+		if len(ui.input) > 0 {
+			ui.input = ui.input[:len(ui.input)-1]
+		}
+	default:
+		if key >= ' ' && key < keyUp {
+			ui.input = append(ui.input, key)
+		}
+	}
+}
+
+// move moves the selection in the focused pane.
+func (ui *uiState) move(delta int) {
+	n := len(ui.sessions)
+	if ui.focus == paneClients {
+		n = len(ui.clients)
+	}
+	i := ui.selectedIndex[ui.focus] + delta
+	if i < 0 || i >= n {
+		return
+	}
+	ui.selectedIndex[ui.focus] = i
+	if ui.focus == paneClients {
+		ui.logs = nil
+	}
+}
+
+// selected returns the selected index of a pane, clamped to the number of items in the pane.
+func (ui *uiState) selected(pane int) int {
+	n := len(ui.sessions)
+	if pane == paneClients {
+		n = len(ui.clients)
+	}
+	if ui.selectedIndex[pane] >= n {
+		ui.selectedIndex[pane] = n - 1
+	}
+	if ui.selectedIndex[pane] < 0 {
+		ui.selectedIndex[pane] = 0
+	}
+	return ui.selectedIndex[pane]
+}
+
+// selectedClient returns the selected client.
+func (ui *uiState) selectedClient() (gonzo.ClientInfo, bool) {
+	if len(ui.clients) == 0 {
+		return gonzo.ClientInfo{}, false
+	}
+	return ui.clients[ui.selected(paneClients)], true
+}
+
+// setStatusFlag sets or clears a status flag of a client.
+func setStatusFlag(client *gonzo.ClientInfo, flag string, on bool) {
+	status := []string{}
+	for _, f := range client.Status {
+		if f != flag {
+			status = append(status, f)
+		}
+	}
+	if on {
+		status = append(status, flag)
+	}
+	client.Status = status
+}
+
+func init() {
+	registerCommand(&Command{
+		Name:    "ui",
+		Summary: "Show a full-screen dashboard of sessions, clients and logs.",
+		Help: func(w io.Writer) {
+			fmt.Fprintf(w, "Keys:\n")
+			fmt.Fprintf(w, "Tab        Switch between the session list and the client table.\n")
+			fmt.Fprintf(w, "Up, Down   Select a session or a client (also k and j).\n")
+			fmt.Fprintf(w, "o, Enter   Open the selected session.\n")
+			fmt.Fprintf(w, "s          Save the current session.\n")
+			fmt.Fprintf(w, "c          Close the current session.\n")
+			fmt.Fprintf(w, "a          Add a client to the current session.\n")
+			fmt.Fprintf(w, "g, h       Show or hide the optional GUI of the selected client.\n")
+			fmt.Fprintf(w, "l          Switch the log pane between stderr and stdout.\n")
+			fmt.Fprintf(w, "q          Quit.\n")
+			fmt.Fprintf(w, "\n")
+			fmt.Fprintf(w, "The log pane follows the selected client.\n")
+		},
+		Run: (*App).UI,
+	})
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Terminal attributes used by the dashboard.
+const (
+	attrReset   = "\x1b[0m"
+	attrBold    = "\x1b[1m"
+	attrDim     = "\x1b[2m"
+	attrReverse = "\x1b[7m"
+)
+
+// Dashboard layout.
+const (
+	// sessionPaneWidth is the maximum width of the session list.
+	sessionPaneWidth = 28
+
+	// uiMinWidth and uiMinHeight are the smallest terminal the dashboard is drawn in.
+	uiMinWidth  = 40
+	uiMinHeight = 10
+)
+
+// uiHelp is shown in the status line when there is nothing else to show.
+const uiHelp = "Tab focus  Up/Down select  o open  s save  c close  a add  g/h gui  l stream  q quit"
+
+// drawUI draws the whole dashboard.
+// The top half of the screen has the session list on the left and the client table
+// on the right, the bottom half has the logs of the selected client.
+func (app *App) drawUI(fd int, ui *uiState) {
+	width, height, err := terminalSize(fd)
+	if err != nil || width == 0 || height == 0 {
+		width, height = 80, 24
+	}
+	if width < uiMinWidth || height < uiMinHeight {
+		writeScreen([]string{fit("terminal too small", width)})
+		return
+	}
+	var (
+		top     = (height - 3) / 2
+		bottom  = height - 3 - top
+		left    = sessionPaneWidth
+		right   int
+		title   = " gonzo " + net.JoinHostPort(app.Host, strconv.Itoa(app.Port))
+		logName = " logs"
+	)
+	if left > width/3 {
+		left = width / 3
+	}
+	right = width - left - 1
+
+	if ui.current >= 0 && ui.current < len(ui.sessions) {
+		title += "  session " + ui.sessions[ui.current].Name
+	} else {
+		title += "  no session open"
+	}
+	if ui.logClient != "" {
+		logName = fmt.Sprintf(" logs of %s (%s)", ui.logClient, ui.logStream)
+	}
+	var (
+		lines    = []string{attrReverse + fit(title, width) + attrReset}
+		sessions = ui.sessionLines(left, top)
+		clients  = ui.clientLines(right, top)
+	)
+	for i := 0; i < top; i++ {
+		lines = append(lines, sessions[i]+attrDim+"|"+attrReset+clients[i])
+	}
+	lines = append(lines, attrReverse+fit(logName, width)+attrReset)
+	lines = append(lines, ui.logLines(width, bottom)...)
+	lines = append(lines, ui.statusLine(width))
+
+	writeScreen(lines)
+}
+
+// writeScreen writes lines from the top left corner of the screen and clears the rest.
+// The whole screen is written at once so that it does not flicker.
+func writeScreen(lines []string) {
+	var buf bytes.Buffer
+
+	buf.WriteString("\x1b[H")
+	buf.WriteString(strings.Join(lines, "\x1b[K\r\n"))
+	buf.WriteString("\x1b[K\x1b[J")
+
+	_, _ = os.Stdout.Write(buf.Bytes())
+}
+
+// sessionLines returns the lines of the session list.
+// The current session is marked with a *.
+func (ui *uiState) sessionLines(width, height int) []string {
+	var (
+		lines    = []string{attrBold + fit(" SESSIONS", width) + attrReset}
+		selected = ui.selected(paneSessions)
+		start    = scrollStart(len(ui.sessions), selected, height-1)
+	)
+	for i := start; i < len(ui.sessions) && len(lines) < height; i++ {
+		marker := "  "
+		if ui.sessions[i].Current {
+			marker = "* "
+		}
+		lines = append(lines, ui.highlight(paneSessions, i == selected, fit(" "+marker+ui.sessions[i].Name, width)))
+	}
+	return padLines(lines, width, height)
+}
+
+// clientLines returns the lines of the client table.
+func (ui *uiState) clientLines(width, height int) []string {
+	const row = " %-16s %-8s %-5s %-8s %s"
+
+	var (
+		header   = fmt.Sprintf(row, "CLIENT", "STATE", "DIRTY", "PROGRESS", "STATUS")
+		lines    = []string{attrBold + fit(header, width) + attrReset}
+		selected = ui.selected(paneClients)
+		start    = scrollStart(len(ui.clients), selected, height-1)
+	)
+	for i := start; i < len(ui.clients) && len(lines) < height; i++ {
+		var (
+			client   = ui.clients[i]
+			state    = "stopped"
+			dirty    string
+			progress string
+		)
+		if client.Running() {
+			state = "running"
+		}
+		if client.Dirty() {
+			dirty = "yes"
+		}
+		if p, ok := ui.progress[client.Name]; ok {
+			progress = fmt.Sprintf("%.0f%%", p*100)
+		}
+		line := fmt.Sprintf(row, client.Name, state, dirty, progress, ui.messages[client.Name])
+		lines = append(lines, ui.highlight(paneClients, i == selected, fit(line, width)))
+	}
+	return padLines(lines, width, height)
+}
+
+// logLines returns the end of the logs, as much as fits in the log pane.
+func (ui *uiState) logLines(width, height int) []string {
+	logs := ui.logs
+	if len(logs) > height {
+		logs = logs[len(logs)-height:]
+	}
+	lines := make([]string, len(logs))
+	for i, line := range logs {
+		lines[i] = fit(line, width)
+	}
+	return padLines(lines, width, height)
+}
+
+// statusLine returns the prompt, the notice or the key help.
+func (ui *uiState) statusLine(width int) string {
+	switch {
+	case ui.prompt != "":
+		return fit(ui.prompt+string(ui.input)+"_", width)
+	case ui.notice != "":
+		return attrBold + fit(ui.notice, width) + attrReset
+	}
+	return attrDim + fit(uiHelp, width) + attrReset
+}
+
+// highlight shows a line in reverse video if it is selected in the focused pane.
+func (ui *uiState) highlight(pane int, selected bool, line string) string {
+	if !selected || ui.focus != pane {
+		return line
+	}
+	return attrReverse + line + attrReset
+}
+
+// scrollStart returns the index of the first of n items that is shown in a pane
+// with room for height items, so that the selected item is visible.
+func scrollStart(n, selected, height int) int {
+	if height <= 0 || n <= height || selected < height {
+		return 0
+	}
+	return selected - height + 1
+}
+
+// padLines pads lines with blank lines up to height.
+func padLines(lines []string, width, height int) []string {
+	for len(lines) < height {
+		lines = append(lines, strings.Repeat(" ", width))
+	}
+	return lines
+}
+
+// fit truncates or pads s with spaces so that it is width runes wide.
+// Control characters are replaced so that they can not mess up the screen.
+func fit(s string, width int) string {
+	var (
+		buf bytes.Buffer
+		n   int
+	)
+	for _, r := range s {
+		if n == width {
+			break
+		}
+		if r < ' ' || r == 0x7f || r == utf8.RuneError {
+			r = ' '
+		}
+		buf.WriteRune(r)
+		n++
+	}
+	for ; n < width; n++ {
+		buf.WriteByte(' ')
+	}
+	return buf.String()
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/gonzoctl/gonzo"
+)
+
+// wait settings.
+const (
+	// waitPollInterval is how often wait asks gonzo for the current session and its clients.
+	waitPollInterval = 500 * time.Millisecond
+
+	// waitSubscribeTimeout is how long wait waits for gonzo to confirm a subscription.
+	// Servers that do not support events never confirm, and wait polls without them.
+	waitSubscribeTimeout = 2 * time.Second
+)
+
+// Conditions that wait can wait for.
+const (
+	conditionLoaded  = "loaded"
+	conditionClean   = "clean"
+	conditionClient  = "client"
+	conditionSession = "session"
+)
+
+// Client states that wait can wait for.
+const (
+	clientRunning = "running"
+	clientGone    = "gone"
+	clientLoaded  = "loaded"
+	clientClean   = "clean"
+)
+
+// clientStates are the states of the client=NAME:STATE condition.
+var clientStates = map[string]bool{clientClean: true, clientGone: true, clientLoaded: true, clientRunning: true}
+
+// waitCondition is a condition of the wait command.
+type waitCondition struct {
+	kind  string
+	name  string
+	state string
+}
+
+// parseCondition parses the value of a --for flag.
+func parseCondition(s string) (waitCondition, error) {
+	switch {
+	case s == conditionLoaded || s == conditionClean:
+		return waitCondition{kind: s}, nil
+	case strings.HasPrefix(s, conditionSession+"="):
+		name := strings.TrimPrefix(s, conditionSession+"=")
+		if name == "" {
+			return waitCondition{}, usageErrorf("expected session name in %s", s)
+		}
+		return waitCondition{kind: conditionSession, name: name}, nil
+	case strings.HasPrefix(s, conditionClient+"="):
+		spec := strings.TrimPrefix(s, conditionClient+"=")
+		i := strings.LastIndex(spec, ":")
+		if i <= 0 || !clientStates[spec[i+1:]] {
+			return waitCondition{}, usageErrorf("expected client=NAME:STATE with STATE one of %s, got %s", strings.Join(sortedKeys(clientStates), ", "), s)
+		}
+		return waitCondition{kind: conditionClient, name: spec[:i], state: spec[i+1:]}, nil
+	}
+	return waitCondition{}, usageErrorf("unrecognized condition: %s", s)
+}
+
+// String returns the condition the way it is written on the command line.
+func (c waitCondition) String() string {
+	switch c.kind {
+	case conditionSession:
+		return conditionSession + "=" + c.name
+	case conditionClient:
+		return conditionClient + "=" + c.name + ":" + c.state
+	}
+	return c.kind
+}
+
+// waitState is what wait knows about gonzo.
+type waitState struct {
+	// session is the name of the current session, it is empty if no session is open.
+	session string
+	clients []gonzo.ClientInfo
+
+	// loaded has the clients that finished opening a session since wait started.
+	loaded map[string]bool
+
+	// ready has the clients that were running in the current session when wait started.
+	// They finished opening it before wait could see them do so, so they count as loaded
+	// until another session is opened.
+	ready map[string]bool
+}
+
+// clientLoaded returns true if the client finished opening the current session.
+func (st waitState) clientLoaded(name string) bool {
+	return st.loaded[name] || st.ready[name]
+}
+
+// setReady marks the clients that are running as ready.
+func (st *waitState) setReady() {
+	st.ready = map[string]bool{}
+	for _, client := range st.clients {
+		if client.Running() {
+			st.ready[client.Name] = true
+		}
+	}
+}
+
+// holds returns true if the condition holds in the state.
+func (c waitCondition) holds(st waitState) bool {
+	switch c.kind {
+	case conditionLoaded:
+		if st.session == "" {
+			return false
+		}
+		for _, client := range st.clients {
+			if !st.clientLoaded(client.Name) {
+				return false
+			}
+		}
+		return true
+	case conditionClean:
+		if st.session == "" {
+			return false
+		}
+		for _, client := range st.clients {
+			if client.Dirty() {
+				return false
+			}
+		}
+		return true
+	case conditionSession:
+		return st.session == c.name
+	}
+	client, ok := gonzo.FindClient(st.clients, c.name)
+
+	switch c.state {
+	case clientRunning:
+		return ok && client.Running()
+	case clientGone:
+		return !ok || !client.Running()
+	case clientLoaded:
+		return ok && st.clientLoaded(c.name)
+	case clientClean:
+		return ok && !client.Dirty()
+	}
+	return false
+}
+
+// WaitFor waits until all of its conditions hold at the same time, or the timeout expires.
+func (app *App) WaitFor(inv *Invocation) error {
+	conditions := []waitCondition{}
+	for _, s := range inv.Strings("for") {
+		c, err := parseCondition(s)
+		if err != nil {
+			return err
+		}
+		conditions = append(conditions, c)
+	}
+	if len(conditions) == 0 {
+		return usageErrorf("expected at least one --for condition")
+	}
+	app.keepServing()
+
+	ctx, cancel := context.WithTimeout(app.ctx, app.Timeout)
+	defer cancel()
+
+	var (
+		poll       = time.NewTicker(waitPollInterval)
+		renew      = time.NewTicker(watchRenewInterval)
+		subscribed = make(chan error, 1)
+		st         = waitState{loaded: map[string]bool{}}
+		pollErr    = app.pollWaitState(ctx, &st)
+	)
+	defer poll.Stop()
+	defer renew.Stop()
+
+	if pollErr == nil {
+		st.setReady()
+	}
+	// Only one subscription is in flight at a time, so the send never blocks.
+	subscribe := func() {
+		sctx, scancel := context.WithTimeout(ctx, waitSubscribeTimeout)
+		defer scancel()
+		subscribed <- app.client.Subscribe(sctx)
+	}
+	go subscribe()
+
+	for {
+		unmet := []string{}
+		for _, c := range conditions {
+			if pollErr != nil || !c.holds(st) {
+				unmet = append(unmet, c.String())
+			}
+		}
+		if len(unmet) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			if app.ctx.Err() != nil {
+				return app.ctx.Err()
+			}
+			msg := fmt.Sprintf("timed out after %s waiting for %s", app.Timeout, strings.Join(unmet, ", "))
+			if pollErr != nil {
+				msg += ": " + pollErr.Error()
+			}
+			return UnmetError{msg: msg}
+		case ev := <-app.client.Events():
+			if ev.Type == gonzo.EventSessionLoaded {
+				st.loaded[ev.Client] = true
+			}
+		case <-poll.C:
+			// A poll that is cut short by the timeout says nothing about gonzo.
+			if err := app.pollWaitState(ctx, &st); ctx.Err() == nil {
+				pollErr = err
+			}
+		case err := <-subscribed:
+			if err != nil {
+				app.debugf("subscribing to events, polling only: %s", err)
+				renew.Stop()
+			} else {
+				renew.Reset(watchRenewInterval)
+			}
+		case <-renew.C:
+			renew.Stop()
+			go subscribe()
+		}
+	}
+}
+
+// pollWaitState gets the current session and its clients.
+func (app *App) pollWaitState(ctx context.Context, st *waitState) error {
+	sessions, current, err := app.client.Sessions(ctx)
+	if err != nil {
+		app.debugf("polling gonzo: %s", err)
+		return err
+	}
+	if current < 0 {
+		st.session, st.clients, st.ready = "", nil, nil
+		return nil
+	}
+	clients, err := app.client.Clients(ctx)
+	if err != nil {
+		app.debugf("polling gonzo: %s", err)
+		return errors.Wrap(err, "listing clients")
+	}
+	if sessions[current].Name != st.session {
+		st.ready = nil
+	}
+	st.session, st.clients = sessions[current].Name, clients
+	return nil
+}
+
+// conditionCandidates returns the conditions for tab completion.
+func (app *App) conditionCandidates() []string {
+	candidates := []string{conditionClean, conditionLoaded}
+	for _, name := range app.sessionNames() {
+		candidates = append(candidates, conditionSession+"="+name)
+	}
+	for _, name := range app.clientNames() {
+		for _, state := range sortedKeys(clientStates) {
+			candidates = append(candidates, conditionClient+"="+name+":"+state)
+		}
+	}
+	return candidates
+}
+
+func init() {
+	registerCommand(&Command{
+		Name:    "wait",
+		Summary: "Wait until a session or its clients reach a state.",
+		Flags: []Flag{
+			{Name: "for", Value: "CONDITION", Default: []string{}, Help: "Condition to wait for, see below. Give --for more than once\nto wait until all the conditions hold at the same time.", Complete: (*App).conditionCandidates},
+		},
+		Help: func(w io.Writer) {
+			fmt.Fprintf(w, "Conditions:\n")
+			fmt.Fprintf(w, "loaded                       A session is open and every client finished opening it.\n")
+			fmt.Fprintf(w, "clean                        A session is open and no client has unsaved changes.\n")
+			fmt.Fprintf(w, "session=NAME                 NAME is the current session.\n")
+			fmt.Fprintf(w, "client=NAME:running          Client NAME is running.\n")
+			fmt.Fprintf(w, "client=NAME:gone             Client NAME is not running or is not in the current session.\n")
+			fmt.Fprintf(w, "client=NAME:loaded           Client NAME finished opening the session.\n")
+			fmt.Fprintf(w, "client=NAME:clean            Client NAME has no unsaved changes.\n")
+			fmt.Fprintf(w, "\n")
+			fmt.Fprintf(w, "wait exits with status 0 as soon as the conditions hold. If they do not hold within\n")
+			fmt.Fprintf(w, "the global -timeout it exits with status %d. wait keeps trying while gonzo restarts.\n", ExitUnmet)
+			fmt.Fprintf(w, "\n")
+			fmt.Fprintf(w, "Clients that are running in the current session when wait starts are loaded.\n")
+			fmt.Fprintf(w, "Other clients are loaded when they send %s, so if a session is\n", gonzo.EventSessionLoaded)
+			fmt.Fprintf(w, "being opened, start wait before opening it to be sure that no client is missed.\n")
+			fmt.Fprintf(w, "%s needs a gonzo server that sends events, the other conditions work with any server.\n", gonzo.EventSessionLoaded)
+			fmt.Fprintf(w, "\n")
+			fmt.Fprintf(w, "Example:\n")
+			fmt.Fprintf(w, "gonzoctl -timeout 1m wait --for session=show --for loaded &\n")
+			fmt.Fprintf(w, "gonzoctl open show\n")
+			fmt.Fprintf(w, "wait $! && start-playback\n")
+		},
+		Run: (*App).WaitFor,
+	})
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/gonzoctl/gonzo"
+)
+
+// watchRenewInterval is how often watch renews its subscription,
+// so that it keeps getting events after gonzo restarts.
+const watchRenewInterval = 10 * time.Second
+
+// Event is the output of the watch command for a single event.
+type Event struct {
+	gonzo.Event
+}
+
+// Rows returns a single row for the event.
+func (e Event) Rows() ([]string, [][]string) {
+	return []string{"TIME", "CLIENT", "EVENT", "DETAIL"}, [][]string{
+		{e.Time.Format(time.RFC3339), e.Client, e.Type, e.Detail()},
+	}
+}
+
+// WriteText writes the event on a single line.
+func (e Event) WriteText(w io.Writer) error {
+	line := fmt.Sprintf("%s %s %s", e.Time.Format("15:04:05"), e.Client, e.Type)
+	if detail := e.Detail(); detail != "" {
+		line += " " + detail
+	}
+	_, err := fmt.Fprintln(w, line)
+	return err
+}
+
+// Watch prints events from gonzo until the app is canceled.
+func (app *App) Watch(inv *Invocation) error {
+	if app.Output == OutputTable && app.Format == "" {
+		return usageErrorf("watch does not support -o table")
+	}
+	app.keepServing()
+
+	if err := app.subscribe(); err != nil {
+		return errors.Wrapf(err, "subscribing to events, does the gonzo server support %s", gonzo.AddressWatch)
+	}
+	var (
+		renew      = time.NewTicker(watchRenewInterval)
+		subscribed = true
+	)
+	defer renew.Stop()
+
+	for {
+		select {
+		case <-app.ctx.Done():
+			return app.ctx.Err()
+		case <-renew.C:
+			err := app.subscribe()
+			if err != nil && subscribed {
+				fmt.Fprintf(os.Stderr, "lost connection to gonzo: %s\n", err)
+			}
+			if err == nil && !subscribed {
+				fmt.Fprintf(os.Stderr, "reconnected to gonzo\n")
+			}
+			subscribed = err == nil
+		case ev := <-app.client.Events():
+			if err := app.printEvent(Event{ev}); err != nil {
+				return errors.Wrap(err, "printing event")
+			}
+		}
+	}
+}
+
+// subscribe asks gonzo to send us events.
+func (app *App) subscribe() error {
+	return app.client.Subscribe(app.ctx)
+}
+
+// printEvent prints an event.
+// With -o json each event is printed as a single line of JSON.
+func (app *App) printEvent(ev Event) error {
+	switch {
+	case app.Format != "":
+		return app.print(ev)
+	case app.Output == OutputJSON:
+		return json.NewEncoder(os.Stdout).Encode(ev)
+	case app.Output == OutputYAML:
+		if _, err := fmt.Println("---"); err != nil {
+			return err
+		}
+	}
+	return app.print(ev)
+}
+
+func init() {
+	registerCommand(&Command{
+		Name:    "watch",
+		Summary: "Print events from a gonzo server as they happen.",
+		Help: func(w io.Writer) {
+			fmt.Fprintf(w, "Events are printed one per line. Use gonzoctl -o json watch to print JSON lines.\n")
+			fmt.Fprintf(w, "\n")
+			fmt.Fprintf(w, "Events are not part of the nsm API. watch needs a gonzo server that accepts\n")
+			fmt.Fprintf(w, "subscriptions on %s and forwards client messages with the name of the\n", gonzo.AddressWatch)
+			fmt.Fprintf(w, "client as their first argument. Other servers reply with an error or time out.\n")
+			fmt.Fprintf(w, "\n")
+			fmt.Fprintf(w, "Event types:\n")
+			fmt.Fprintf(w, "%-16s a client has unsaved changes\n", gonzo.EventDirty)
+			fmt.Fprintf(w, "%-16s a client has saved its changes\n", gonzo.EventClean)
+			fmt.Fprintf(w, "%-16s a client reported progress\n", gonzo.EventProgress)
+			fmt.Fprintf(w, "%-16s a client sent a status message\n", gonzo.EventStatus)
+			fmt.Fprintf(w, "%-16s a client's optional GUI is showing\n", gonzo.EventGUIShowing)
+			fmt.Fprintf(w, "%-16s a client's optional GUI is hidden\n", gonzo.EventGUIHidden)
+			fmt.Fprintf(w, "%-16s a client was launched\n", gonzo.EventLaunched)
+			fmt.Fprintf(w, "%-16s a client exited\n", gonzo.EventExited)
+			fmt.Fprintf(w, "%-16s a client finished opening the session\n", gonzo.EventSessionLoaded)
+		},
+		Run: (*App).Watch,
+	})
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Web serves the built-in control panel.
+func (app *App) Web(inv *Invocation) error {
+	app.keepServing()
+
+	mux := app.apiHandler(nil)
+	mux.HandleFunc("/", handlePanel)
+
+	return app.listenAndServe(inv.String("listen"), mux)
+}
+
+// handlePanel serves the control panel page.
+// Everything the page needs is in the page, so that it works without internet access.
+func handlePanel(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		allowMethods(w, http.MethodGet, http.MethodHead)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = io.WriteString(w, panelHTML)
+}
+
+func init() {
+	registerCommand(&Command{
+		Name:    "web",
+		Summary: "Serve a control panel for gonzo that can be used from a web browser.",
+		Flags: []Flag{
+			{Name: "listen", Value: "ADDRESS", Default: DefaultHTTPListen, Help: fmt.Sprintf("Address to listen on (default %s).", DefaultHTTPListen)},
+		},
+		Help: func(w io.Writer) {
+			fmt.Fprintf(w, "The panel lists sessions and the clients of the current session, and can open, save,\n")
+			fmt.Fprintf(w, "create and remove sessions, add clients and show client logs. It updates as events happen.\n")
+			fmt.Fprintf(w, "It is built into gonzoctl and does not load anything from the internet.\n")
+			fmt.Fprintf(w, "\n")
+			fmt.Fprintf(w, "The panel has no authentication. Anyone who can reach ADDRESS can control gonzo,\n")
+			fmt.Fprintf(w, "so only listen on trusted networks, e.g. --listen :8080 to use it from other machines.\n")
+			fmt.Fprintf(w, "The HTTP API and the WebSocket bridge of serve-http are served too, and only\n")
+			fmt.Fprintf(w, "the panel itself can use them from a browser.\n")
+		},
+		Run: (*App).Web,
+	})
+}
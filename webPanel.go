@@ -0,0 +1,258 @@
+package main
+
+// panelHTML is the page of the web control panel.
+// It talks to gonzoctl over the WebSocket bridge and refreshes when events arrive.
+// Backquotes can not be used in the page because it is a raw string literal.
+const panelHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>gonzo</title>
+<style>
+  body { font-family: sans-serif; margin: 0; background: #1d1f21; color: #e0e0e0; }
+  header { display: flex; align-items: center; gap: 1em; padding: .6em 1em; background: #303236; }
+  header h1 { font-size: 1.1em; margin: 0; }
+  #connection { margin-left: auto; font-size: .85em; }
+  #connection.down { color: #f07070; }
+  main { padding: 0 1em 1em; max-width: 60em; }
+  h2 { font-size: 1em; margin: 1.2em 0 .4em; color: #a0a8b0; text-transform: uppercase; }
+  table { width: 100%; border-collapse: collapse; }
+  td, th { text-align: left; padding: .45em .4em; border-bottom: 1px solid #3a3c40; }
+  tr.current td { font-weight: bold; color: #8fd18f; }
+  button { font-size: 1em; padding: .35em .7em; margin: .1em; border: 0; border-radius: 4px; background: #4a6f9a; color: #fff; }
+  button.danger { background: #9a4a4a; }
+  input { font-size: 1em; padding: .35em; margin: .1em; border-radius: 4px; border: 1px solid #555; background: #2a2c30; color: #e0e0e0; }
+  form { display: flex; flex-wrap: wrap; gap: .3em; margin: .5em 0; }
+  .running { color: #8fd18f; }
+  .stopped { color: #a0a0a0; }
+  .dirty { color: #e8c060; }
+  #error { display: none; padding: .6em 1em; background: #6b2b2b; }
+  #logs { white-space: pre-wrap; font-family: monospace; font-size: .85em; background: #111; padding: .6em; max-height: 40vh; overflow: auto; }
+</style>
+</head>
+<body>
+<header>
+  <h1>gonzo</h1>
+  <span id="session">no session open</span>
+  <span id="connection" class="down">connecting</span>
+</header>
+<div id="error"></div>
+<main>
+  <h2>Current session</h2>
+  <div>
+    <button id="save">Save</button>
+    <button id="close">Close</button>
+  </div>
+
+  <h2>Clients</h2>
+  <table>
+    <thead><tr><th>Name</th><th>State</th><th>Status</th><th></th></tr></thead>
+    <tbody id="clients"></tbody>
+  </table>
+  <form id="add">
+    <input name="name" placeholder="client name" required>
+    <input name="executable" placeholder="executable" required>
+    <button>Add client</button>
+  </form>
+
+  <h2>Sessions</h2>
+  <table>
+    <tbody id="sessions"></tbody>
+  </table>
+  <form id="new">
+    <input name="name" placeholder="session name" required>
+    <button>New session</button>
+  </form>
+
+  <h2 id="logs-title">Logs</h2>
+  <div id="logs">Select a client to see its logs.</div>
+</main>
+<script>
+"use strict";
+
+var ws = null, nextID = 1, pending = {}, messages = {}, logClient = null, logStream = "stderr", refreshTimer = null;
+
+function $(id) { return document.getElementById(id); }
+
+function el(tag, text, cls) {
+  var e = document.createElement(tag);
+  if (text !== undefined) { e.textContent = text; }
+  if (cls) { e.className = cls; }
+  return e;
+}
+
+function button(text, onclick, cls) {
+  var b = el("button", text, cls);
+  b.onclick = onclick;
+  return b;
+}
+
+function showError(err) {
+  $("error").textContent = err;
+  $("error").style.display = err ? "block" : "none";
+}
+
+// send sends a command over the WebSocket bridge and returns a promise of the result.
+function send(command, args) {
+  return new Promise(function(resolve, reject) {
+    if (!ws || ws.readyState !== WebSocket.OPEN) {
+      reject("not connected to gonzoctl");
+      return;
+    }
+    var id = String(nextID++);
+    pending[id] = { resolve: resolve, reject: reject };
+    ws.send(JSON.stringify({ id: id, command: command, args: args || [] }));
+  });
+}
+
+// run runs a command, shows its error if it fails and refreshes the page.
+function run(command, args) {
+  return send(command, args).then(function() { showError(""); }, showError).then(refresh);
+}
+
+function refresh() {
+  send("list").then(function(list) {
+    renderSessions(list);
+    if (list.current < 0) {
+      renderClients([]);
+      return;
+    }
+    return send("clients").then(renderClients).then(refreshLogs);
+  }).catch(showError);
+}
+
+// refreshSoon refreshes once after a burst of events.
+function refreshSoon() {
+  clearTimeout(refreshTimer);
+  refreshTimer = setTimeout(refresh, 250);
+}
+
+function renderSessions(list) {
+  var body = $("sessions");
+  body.textContent = "";
+  $("session").textContent = list.current >= 0 ? "session " + list.sessions[list.current].name : "no session open";
+  list.sessions.forEach(function(s) {
+    var tr = el("tr", undefined, s.current ? "current" : "");
+    tr.appendChild(el("td", s.name));
+    var actions = el("td");
+    actions.appendChild(button("Open", function() { run("open", [s.name]); }));
+    actions.appendChild(button("Remove", function() {
+      if (confirm("Remove session " + s.name + "?")) { run("rm", [s.name]); }
+    }, "danger"));
+    tr.appendChild(actions);
+    body.appendChild(tr);
+  });
+}
+
+function renderClients(clients) {
+  var body = $("clients");
+  body.textContent = "";
+  clients.forEach(function(c) {
+    var status = c.status || [],
+        running = status.indexOf("running") >= 0,
+        dirty = status.indexOf("dirty") >= 0,
+        tr = el("tr");
+    tr.appendChild(el("td", c.name));
+    var state = el("td", running ? "running" : "stopped", running ? "running" : "stopped");
+    if (dirty) { state.appendChild(el("span", " unsaved", "dirty")); }
+    tr.appendChild(state);
+    tr.appendChild(el("td", messages[c.name] || ""));
+    var actions = el("td");
+    actions.appendChild(button("Logs", function() { showLogs(c.name); }));
+    tr.appendChild(actions);
+    body.appendChild(tr);
+  });
+}
+
+function showLogs(client) {
+  if (logClient === client) {
+    logStream = logStream === "stderr" ? "stdout" : "stderr";
+  }
+  logClient = client;
+  refreshLogs();
+}
+
+function refreshLogs() {
+  if (!logClient) { return; }
+  $("logs-title").textContent = "Logs of " + logClient + " (" + logStream + ", press Logs again to switch)";
+  return send("logs", [logClient, logStream]).then(function(logs) {
+    var box = $("logs");
+    box.textContent = (logs.lines || []).join("\n");
+    box.scrollTop = box.scrollHeight;
+  }, showError);
+}
+
+function handleEvent(ev) {
+  if (ev.type === "status") {
+    messages[ev.client] = ev.message;
+  } else if (ev.progress !== undefined) {
+    messages[ev.client] = Math.round(ev.progress * 100) + "%";
+  }
+  refreshSoon();
+}
+
+function connect() {
+  var scheme = location.protocol === "https:" ? "wss://" : "ws://";
+  ws = new WebSocket(scheme + location.host + "/ws");
+  ws.onopen = function() {
+    $("connection").textContent = "connected";
+    $("connection").className = "";
+    refresh();
+  };
+  ws.onclose = function() {
+    $("connection").textContent = "disconnected, retrying";
+    $("connection").className = "down";
+    Object.keys(pending).forEach(function(id) { pending[id].reject("lost connection to gonzoctl"); });
+    pending = {};
+    setTimeout(connect, 2000);
+  };
+  ws.onmessage = function(e) {
+    var msg = JSON.parse(e.data), p = pending[msg.id];
+    switch (msg.type) {
+    case "reply":
+    case "error":
+      if (!p) { return; }
+      delete pending[msg.id];
+      if (msg.type === "reply") { p.resolve(msg.result); } else { p.reject(msg.error); }
+      break;
+    case "event":
+      handleEvent(msg.event);
+      break;
+    case "disconnected":
+      showError("lost connection to gonzo: " + msg.error);
+      break;
+    case "reconnected":
+      showError("");
+      refresh();
+      break;
+    }
+  };
+}
+
+function formValues(form) {
+  var values = {};
+  Array.prototype.forEach.call(form.elements, function(e) {
+    if (e.name) { values[e.name] = e.value.trim(); }
+  });
+  return values;
+}
+
+$("save").onclick = function() { run("save"); };
+$("close").onclick = function() { run("close"); };
+$("new").onsubmit = function(e) {
+  e.preventDefault();
+  run("new", [formValues(this).name]);
+  this.reset();
+};
+$("add").onsubmit = function(e) {
+  e.preventDefault();
+  var v = formValues(this);
+  run("add", [v.name, v.executable]);
+  this.reset();
+};
+connect();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/gonzoctl/gonzo"
+	"github.com/scgolang/nsm"
+	"golang.org/x/net/websocket"
+)
+
+// Types of the messages that the WebSocket bridge sends.
+const (
+	bridgeEvent        = "event"
+	bridgeReply        = "reply"
+	bridgeError        = "error"
+	bridgeDisconnected = "disconnected"
+	bridgeReconnected  = "reconnected"
+)
+
+// WebSocket bridge settings.
+const (
+	// bridgeBufferSize is how many messages are queued for a WebSocket connection
+	// before events are dropped for it.
+	bridgeBufferSize = 64
+
+	// nsmServerPrefix is the prefix of the addresses of server requests,
+	// which can be left out of bridge command names.
+	nsmServerPrefix = "/nsm/server/"
+)
+
+// bridgeMessage is a message that the WebSocket bridge sends to browsers.
+type bridgeMessage struct {
+	Type    string       `json:"type"`
+	ID      string       `json:"id,omitempty"`
+	Event   *gonzo.Event `json:"event,omitempty"`
+	Result  interface{}  `json:"result,omitempty"`
+	Error   string       `json:"error,omitempty"`
+	Code    nsm.Code     `json:"code,omitempty"`
+	Address string       `json:"address,omitempty"`
+}
+
+// bridgeRequest is a command that browsers send to the WebSocket bridge.
+type bridgeRequest struct {
+	ID      string   `json:"id"`
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+}
+
+// bridgeCommand is a command that can be sent over the WebSocket bridge.
+type bridgeCommand struct {
+	args int
+	run  func(ctx context.Context, c *gonzo.Client, args []string) (interface{}, error)
+}
+
+// bridgeCommands are the commands that can be sent over the WebSocket bridge.
+// They are named after the /nsm/server/* requests they make,
+// which can also be used as command names.
+var bridgeCommands = map[string]bridgeCommand{
+	"abort": {0, func(ctx context.Context, c *gonzo.Client, args []string) (interface{}, error) {
+		return nil, c.AbortSession(ctx)
+	}},
+	"add": {2, func(ctx context.Context, c *gonzo.Client, args []string) (interface{}, error) {
+		return nil, c.Add(ctx, args[0], args[1])
+	}},
+	"clients": {0, func(ctx context.Context, c *gonzo.Client, args []string) (interface{}, error) {
+		clients, err := c.Clients(ctx)
+		return ClientList(clients), err
+	}},
+	"close": {0, func(ctx context.Context, c *gonzo.Client, args []string) (interface{}, error) {
+		return nil, c.CloseSession(ctx)
+	}},
+	"duplicate": {2, func(ctx context.Context, c *gonzo.Client, args []string) (interface{}, error) {
+		return nil, c.DuplicateSession(ctx, args[0], args[1])
+	}},
+	"list": {0, func(ctx context.Context, c *gonzo.Client, args []string) (interface{}, error) {
+		sessions, current, err := c.Sessions(ctx)
+		return SessionList{Current: current, Sessions: sessions}, err
+	}},
+	"logs": {2, func(ctx context.Context, c *gonzo.Client, args []string) (interface{}, error) {
+		lines, err := c.Logs(ctx, args[0], args[1])
+		return Logs{Client: args[0], Stream: args[1], Lines: lines}, err
+	}},
+	"new": {1, func(ctx context.Context, c *gonzo.Client, args []string) (interface{}, error) {
+		return nil, c.NewSession(ctx, args[0])
+	}},
+	"open": {1, func(ctx context.Context, c *gonzo.Client, args []string) (interface{}, error) {
+		return nil, c.OpenSession(ctx, args[0])
+	}},
+	"quit": {0, func(ctx context.Context, c *gonzo.Client, args []string) (interface{}, error) {
+		return nil, c.Quit(ctx)
+	}},
+	"rm": {1, func(ctx context.Context, c *gonzo.Client, args []string) (interface{}, error) {
+		return nil, c.RemoveSession(ctx, args[0])
+	}},
+	"save": {0, func(ctx context.Context, c *gonzo.Client, args []string) (interface{}, error) {
+		return nil, c.SaveSession(ctx)
+	}},
+}
+
+// sortedBridgeCommands returns the names and arguments of the bridge commands in order.
+func sortedBridgeCommands() []string {
+	names := make([]string, 0, len(bridgeCommands))
+	for name, cmd := range bridgeCommands {
+		names = append(names, fmt.Sprintf("%s (%d)", name, cmd.args))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// eventHub sends the events from gonzo to every WebSocket connection.
+type eventHub struct {
+	mu        sync.Mutex
+	listeners map[chan bridgeMessage]struct{}
+}
+
+// listen returns a channel that gets every message the hub sends.
+func (h *eventHub) listen() chan bridgeMessage {
+	ch := make(chan bridgeMessage, bridgeBufferSize)
+
+	h.mu.Lock()
+	h.listeners[ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch
+}
+
+// forget stops sending messages to a channel that was returned by listen.
+func (h *eventHub) forget(ch chan bridgeMessage) {
+	h.mu.Lock()
+	delete(h.listeners, ch)
+	h.mu.Unlock()
+}
+
+// send sends a message to every listener.
+// Listeners that are not keeping up miss the message.
+func (h *eventHub) send(msg bridgeMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.listeners {
+		select {
+		case ch <- msg:
+		default:
+		}
+	}
+}
+
+// runEventHub subscribes to events from gonzo and returns a hub that forwards them.
+// The subscription is renewed so that events keep coming after gonzo restarts,
+// and listeners are told when the connection to gonzo is lost and when it comes back.
+func (app *App) runEventHub() *eventHub {
+	hub := &eventHub{listeners: map[chan bridgeMessage]struct{}{}}
+
+	go func() {
+		var (
+			renew      = time.NewTicker(watchRenewInterval)
+			subscribed = app.subscribe() == nil
+		)
+		defer renew.Stop()
+
+		for {
+			select {
+			case <-app.ctx.Done():
+				return
+			case <-renew.C:
+				err := app.subscribe()
+				if err != nil && subscribed {
+					hub.send(bridgeMessage{Type: bridgeDisconnected, Error: err.Error()})
+				}
+				if err == nil && !subscribed {
+					hub.send(bridgeMessage{Type: bridgeReconnected})
+				}
+				subscribed = err == nil
+			case ev := <-app.client.Events():
+				hub.send(bridgeMessage{Type: bridgeEvent, Event: &ev})
+			}
+		}
+	}()
+	return hub
+}
+
+// websocketHandler returns the handler for the WebSocket bridge.
+// Browsers can only connect from the same origin as the bridge or from
+// one of allowedOrigins, where * allows any origin.
+func (app *App) websocketHandler(hub *eventHub, allowedOrigins []string) http.Handler {
+	return websocket.Server{
+		Handshake: func(config *websocket.Config, r *http.Request) error {
+			return checkOrigin(config, r, allowedOrigins)
+		},
+		Handler: func(ws *websocket.Conn) {
+			app.serveWebSocket(ws, hub)
+		},
+	}
+}
+
+// checkOrigin checks the origin of a WebSocket connection.
+// Connections without an origin are not from a browser and are always allowed.
+func checkOrigin(config *websocket.Config, r *http.Request, allowedOrigins []string) error {
+	origin, err := websocket.Origin(config, r)
+	if err != nil {
+		return errors.Wrap(err, "parsing origin")
+	}
+	config.Origin = origin
+
+	if !originAllowed(origin, r.Host, allowedOrigins) {
+		return errors.Errorf("origin %s is not allowed", origin)
+	}
+	return nil
+}
+
+// originAllowed returns true if a page from origin can use a server at host.
+// A nil origin is not from a browser and is always allowed.
+func originAllowed(origin *url.URL, host string, allowedOrigins []string) bool {
+	if origin == nil || origin.Host == host {
+		return true
+	}
+	for _, allowed := range allowedOrigins {
+		if allowed == "*" || allowed == origin.String() || allowed == origin.Host {
+			return true
+		}
+	}
+	return false
+}
+
+// serveWebSocket sends events to a WebSocket connection and runs the commands it receives.
+// Commands run concurrently, so replies can arrive in a different order than the commands were sent.
+func (app *App) serveWebSocket(ws *websocket.Conn, hub *eventHub) {
+	var (
+		ctx, cancel = context.WithCancel(app.ctx)
+		events      = hub.listen()
+		replies     = make(chan bridgeMessage, bridgeBufferSize)
+	)
+	defer cancel()
+	defer hub.forget(events)
+
+	go func() {
+		defer cancel()
+
+		for {
+			var req bridgeRequest
+			if err := websocket.JSON.Receive(ws, &req); err != nil {
+				app.debugf("reading from websocket %s: %s", ws.Request().RemoteAddr, err)
+				return
+			}
+			go func() {
+				select {
+				case replies <- app.runBridgeCommand(ctx, req):
+				case <-ctx.Done():
+				}
+			}()
+		}
+	}()
+	for {
+		var msg bridgeMessage
+
+		select {
+		case <-ctx.Done():
+			return
+		case msg = <-events:
+		case msg = <-replies:
+		}
+		if err := websocket.JSON.Send(ws, msg); err != nil {
+			app.debugf("writing to websocket %s: %s", ws.Request().RemoteAddr, err)
+			return
+		}
+	}
+}
+
+// runBridgeCommand runs a command that was received over the WebSocket bridge.
+func (app *App) runBridgeCommand(ctx context.Context, req bridgeRequest) bridgeMessage {
+	name := strings.TrimPrefix(req.Command, nsmServerPrefix)
+
+	cmd, ok := bridgeCommands[name]
+	if !ok {
+		return bridgeErrorMessage(req.ID, usageErrorf("unrecognized command: %s", req.Command))
+	}
+	if expected, got := cmd.args, len(req.Args); expected != got {
+		return bridgeErrorMessage(req.ID, usageErrorf("%s expects %d arguments, got %d", name, expected, got))
+	}
+	result, err := cmd.run(ctx, app.client, req.Args)
+	if err != nil {
+		return bridgeErrorMessage(req.ID, err)
+	}
+	return bridgeMessage{Type: bridgeReply, ID: req.ID, Result: result}
+}
+
+// bridgeErrorMessage returns the message for a command that failed.
+func bridgeErrorMessage(id string, err error) bridgeMessage {
+	msg := bridgeMessage{Type: bridgeError, ID: id, Error: err.Error()}
+	if e, ok := errors.Cause(err).(gonzo.Error); ok {
+		msg.Code, msg.Address = e.Code(), e.Address
+	}
+	return msg
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// yamlField is a key/value pair of a YAML mapping.
+type yamlField struct {
+	key   string
+	value interface{}
+}
+
+// yamlMap is a YAML mapping that keeps the order of its keys.
+type yamlMap []yamlField
+
+// marshalYAML encodes v as YAML.
+// v is first encoded as JSON so that it honors json struct tags and json.Marshaler.
+func marshalYAML(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	node, err := decodeJSONNode(dec)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding json")
+	}
+	var buf bytes.Buffer
+	writeYAMLNode(&buf, node, 0)
+	return buf.Bytes(), nil
+}
+
+// decodeJSONNode decodes the next JSON value, keeping the order of object keys.
+func decodeJSONNode(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		m := yamlMap{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeJSONNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, yamlField{key: key.(string), value: value})
+		}
+		_, err := dec.Token()
+		return m, err
+	case json.Delim('['):
+		l := []interface{}{}
+		for dec.More() {
+			value, err := decodeJSONNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, value)
+		}
+		_, err := dec.Token()
+		return l, err
+	}
+	return tok, nil
+}
+
+// writeYAMLNode writes a node in block style.
+// The node starts at the current position of buf, which is at column indent.
+func writeYAMLNode(buf *bytes.Buffer, node interface{}, indent int) {
+	pad := strings.Repeat(" ", indent)
+
+	switch x := node.(type) {
+	case yamlMap:
+		if len(x) == 0 {
+			buf.WriteString("{}\n")
+			return
+		}
+		for i, field := range x {
+			if i > 0 {
+				buf.WriteString(pad)
+			}
+			buf.WriteString(yamlScalar(field.key) + ":")
+
+			if !isYAMLCollection(field.value) {
+				buf.WriteString(" ")
+				writeYAMLNode(buf, field.value, indent)
+				continue
+			}
+			buf.WriteString("\n" + pad + "  ")
+			writeYAMLNode(buf, field.value, indent+2)
+		}
+	case []interface{}:
+		if len(x) == 0 {
+			buf.WriteString("[]\n")
+			return
+		}
+		for i, item := range x {
+			if i > 0 {
+				buf.WriteString(pad)
+			}
+			buf.WriteString("- ")
+			writeYAMLNode(buf, item, indent+2)
+		}
+	default:
+		buf.WriteString(yamlScalar(x) + "\n")
+	}
+}
+
+// isYAMLCollection returns true if node is a mapping or a list that is not empty.
+func isYAMLCollection(node interface{}) bool {
+	switch x := node.(type) {
+	case yamlMap:
+		return len(x) > 0
+	case []interface{}:
+		return len(x) > 0
+	}
+	return false
+}
+
+// yamlScalar formats a JSON scalar as a YAML scalar.
+func yamlScalar(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(x)
+	case json.Number:
+		return x.String()
+	case string:
+		if yamlNeedsQuotes(x) {
+			return strconv.Quote(x)
+		}
+		return x
+	}
+	return ""
+}
+
+// yamlNeedsQuotes returns true if s would not be read back as the same plain string.
+func yamlNeedsQuotes(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s {
+		return true
+	}
+	switch strings.ToLower(s) {
+	case "null", "~", "true", "false", "yes", "no", "on", "off", "y", "n":
+		return true
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return true
+	}
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") {
+		return true
+	}
+	for _, r := range s {
+		if r < ' ' || r == 0x7f {
+			return true
+		}
+	}
+	return strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":")
+}
+
+// yamlLine is a line of a YAML document without its indentation and comment.
+type yamlLine struct {
+	n      int
+	indent int
+	text   string
+}
+
+// yamlParser reads the block style written by marshalYAML, plus comments and quoted scalars.
+// Flow collections other than [] and {}, anchors, tags and multi-line scalars are not supported.
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// yamlToJSON converts a YAML document to JSON.
+func yamlToJSON(data []byte) ([]byte, error) {
+	p := &yamlParser{}
+	for i, line := range strings.Split(string(data), "\n") {
+		text := strings.TrimRight(stripYAMLComment(line), " \t\r")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || (trimmed == "---" && len(p.lines) == 0) {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, errors.Errorf("line %d: tabs can not be used for indentation", i+1)
+		}
+		p.lines = append(p.lines, yamlLine{n: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	if len(p.lines) == 0 {
+		return []byte("null"), nil
+	}
+	node, err := p.node(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, errors.Errorf("line %d: unexpected indentation", p.lines[p.pos].n)
+	}
+	return json.Marshal(node)
+}
+
+// node parses the collection or scalar that starts at the current line, which is at column indent.
+func (p *yamlParser) node(indent int) (interface{}, error) {
+	line := p.lines[p.pos]
+	if line.indent != indent {
+		return nil, errors.Errorf("line %d: unexpected indentation", line.n)
+	}
+	if isYAMLItem(line.text) {
+		return p.sequence(indent)
+	}
+	if _, _, ok := splitYAMLKey(line.text); ok {
+		return p.mapping(indent)
+	}
+	p.pos++
+	return parseYAMLScalar(line.text, line.n)
+}
+
+// sequence parses the items of a block sequence at column indent.
+func (p *yamlParser) sequence(indent int) (interface{}, error) {
+	l := []interface{}{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isYAMLItem(p.lines[p.pos].text) {
+		line := &p.lines[p.pos]
+		rest := strings.TrimLeft(line.text[1:], " ")
+		if rest == "" {
+			p.pos++
+			item, err := p.value(indent, false)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, item)
+			continue
+		}
+		// The item starts on the same line as the dash, as if it was on the next line.
+		line.indent, line.text = indent+len(line.text)-len(rest), rest
+
+		item, err := p.node(line.indent)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, item)
+	}
+	return l, nil
+}
+
+// mapping parses the fields of a block mapping at column indent.
+func (p *yamlParser) mapping(indent int) (interface{}, error) {
+	m := map[string]interface{}{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && !isYAMLItem(p.lines[p.pos].text) {
+		line := p.lines[p.pos]
+		key, rest, ok := splitYAMLKey(line.text)
+		if !ok {
+			return nil, errors.Errorf("line %d: expected key: value, got %s", line.n, line.text)
+		}
+		if _, ok := m[key]; ok {
+			return nil, errors.Errorf("line %d: duplicate key %s", line.n, key)
+		}
+		p.pos++
+
+		if rest != "" {
+			value, err := parseYAMLScalar(rest, line.n)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = value
+			continue
+		}
+		value, err := p.value(indent, true)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = value
+	}
+	return m, nil
+}
+
+// value parses the value that follows a key or a dash on its own line at column indent.
+// The value is null if the next line is not indented further,
+// unless it is a sequence that is allowed at the same column, like the value of a key.
+func (p *yamlParser) value(indent int, sameColumnItems bool) (interface{}, error) {
+	if p.pos == len(p.lines) {
+		return nil, nil
+	}
+	next := p.lines[p.pos]
+	if next.indent > indent || (sameColumnItems && next.indent == indent && isYAMLItem(next.text)) {
+		return p.node(next.indent)
+	}
+	return nil, nil
+}
+
+// isYAMLItem returns true if text is an item of a block sequence.
+func isYAMLItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitYAMLKey splits a key: value line.
+// rest is empty if the value is on the following lines.
+func splitYAMLKey(text string) (key, rest string, ok bool) {
+	end := 0
+	if text[0] == '"' || text[0] == '\'' {
+		end = closingQuote(text)
+		if end < 0 {
+			return "", "", false
+		}
+		end++
+	}
+	i := strings.Index(text[end:], ":")
+	for i >= 0 {
+		j := end + i
+		if j+1 == len(text) || text[j+1] == ' ' {
+			key = strings.TrimSpace(text[:j])
+			if key == "" {
+				return "", "", false
+			}
+			if key[0] == '"' || key[0] == '\'' {
+				unquoted, err := parseYAMLScalar(key, 0)
+				if err != nil {
+					return "", "", false
+				}
+				key = unquoted.(string)
+			}
+			return key, strings.TrimSpace(text[j+1:]), true
+		}
+		end = j + 1
+		i = strings.Index(text[end:], ":")
+	}
+	return "", "", false
+}
+
+// parseYAMLScalar parses a plain or quoted scalar, or an empty flow collection.
+// n is the line number used in errors.
+func parseYAMLScalar(text string, n int) (interface{}, error) {
+	switch text[0] {
+	case '"':
+		if closingQuote(text) != len(text)-1 {
+			return nil, errors.Errorf("line %d: unterminated string %s", n, text)
+		}
+		s, err := strconv.Unquote(text)
+		return s, errors.Wrapf(err, "line %d: unquoting %s", n, text)
+	case '\'':
+		if closingQuote(text) != len(text)-1 {
+			return nil, errors.Errorf("line %d: unterminated string %s", n, text)
+		}
+		return strings.Replace(text[1:len(text)-1], "''", "'", -1), nil
+	case '[', '{':
+		switch text {
+		case "[]":
+			return []interface{}{}, nil
+		case "{}":
+			return map[string]interface{}{}, nil
+		}
+		return nil, errors.Errorf("line %d: flow collections are not supported, got %s", n, text)
+	case '&', '*', '!', '|', '>':
+		return nil, errors.Errorf("line %d: unsupported yaml %s", n, text)
+	}
+	switch text {
+	case "null", "Null", "NULL", "~":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	if _, err := strconv.ParseFloat(text, 64); err == nil {
+		return json.Number(text), nil
+	}
+	return text, nil
+}
+
+// closingQuote returns the index of the quote that closes the string at the start of text, or -1.
+func closingQuote(text string) int {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case text[i] == quote && quote == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// stripYAMLComment removes a comment from a line.
+// Comments start with a # at the start of the line or after a space, outside quotes.
+func stripYAMLComment(line string) string {
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case (c == '"' || c == '\'') && (i == 0 || strings.ContainsRune(" -:", rune(line[i-1]))):
+			end := closingQuote(line[i:])
+			if end < 0 {
+				return line
+			}
+			i += end
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestYAMLToJSON(t *testing.T) {
+	for _, testcase := range []struct {
+		name     string
+		yaml     string
+		expected string
+	}{
+		{name: "empty document", yaml: "", expected: `null`},
+		{name: "document start", yaml: "---\nname: show\n", expected: `{"name":"show"}`},
+		{name: "scalars", yaml: "a: 1\nb: -2.5\nc: true\nd: False\ne: null\nf: ~\ng: hello world\n", expected: `{"a":1,"b":-2.5,"c":true,"d":false,"e":null,"f":null,"g":"hello world"}`},
+		{name: "missing value", yaml: "a:\nb: 1\n", expected: `{"a":null,"b":1}`},
+		{name: "double quotes", yaml: `a: "x: y"` + "\n" + `b: "true"` + "\n" + `c: "tab\there"` + "\n" + `d: ""` + "\n", expected: `{"a":"x: y","b":"true","c":"tab\there","d":""}`},
+		{name: "single quotes", yaml: "a: 'it''s'\nb: '1'\n", expected: `{"a":"it's","b":"1"}`},
+		{name: "quoted key", yaml: `"a: b": 1` + "\n" + `'c': 2` + "\n", expected: `{"a: b":1,"c":2}`},
+		{name: "colon without space", yaml: "url: http://host:8080/\n", expected: `{"url":"http://host:8080/"}`},
+		{name: "comments", yaml: "# a session\nname: show # the name\nexecutable: a#b\nquoted: 'x # y'\n  # indented comment\n", expected: `{"executable":"a#b","name":"show","quoted":"x # y"}`},
+		{name: "empty collections", yaml: "a: []\nb: {}\n", expected: `{"a":[],"b":{}}`},
+		{
+			name:     "list of maps",
+			yaml:     "name: show\nclients:\n  - name: synth\n    executable: zynaddsubfx\n    gui: true\n  - name: drums\n    executable: hydrogen\n",
+			expected: `{"clients":[{"executable":"zynaddsubfx","gui":true,"name":"synth"},{"executable":"hydrogen","name":"drums"}],"name":"show"}`,
+		},
+		{name: "list at the column of its key", yaml: "clients:\n- name: synth\n- name: drums\n", expected: `{"clients":[{"name":"synth"},{"name":"drums"}]}`},
+		{name: "nested lists", yaml: "- - a\n  - b\n-\n  - c\n- d\n", expected: `[["a","b"],["c"],"d"]`},
+		{name: "nested maps", yaml: "a:\n  b:\n    c: 1\n  d: 2\n", expected: `{"a":{"b":{"c":1},"d":2}}`},
+		{name: "windows line endings", yaml: "a: 1\r\nb: 2\r\n", expected: `{"a":1,"b":2}`},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			got, err := yamlToJSON([]byte(testcase.yaml))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := testcase.expected; expected != string(got) {
+				t.Fatalf("expected %s, got %s", expected, got)
+			}
+		})
+	}
+}
+
+func TestYAMLToJSONErrors(t *testing.T) {
+	for _, testcase := range []struct {
+		name string
+		yaml string
+	}{
+		{name: "tab indentation", yaml: "a:\n\t- b\n"},
+		{name: "flow sequence", yaml: "a: [1, 2]\n"},
+		{name: "flow mapping", yaml: "a: {b: 1}\n"},
+		{name: "anchor", yaml: "a: &x 1\n"},
+		{name: "alias", yaml: "a: *x\n"},
+		{name: "tag", yaml: "a: !!str 1\n"},
+		{name: "block scalar", yaml: "a: |\n  text\n"},
+		{name: "duplicate key", yaml: "a: 1\na: 2\n"},
+		{name: "unterminated double quote", yaml: `a: "x` + "\n"},
+		{name: "unterminated single quote", yaml: "a: 'x\n"},
+		{name: "bad escape", yaml: `a: "\q"` + "\n"},
+		{name: "unexpected indentation", yaml: "a: 1\n  b: 2\n"},
+		{name: "item in a mapping", yaml: "a: 1\n- b\n"},
+		{name: "key in a sequence", yaml: "- a\nb: 1\n"},
+		{name: "multi-line scalar", yaml: "a\nb\n"},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			if got, err := yamlToJSON([]byte(testcase.yaml)); err == nil {
+				t.Fatalf("expected error, got %s", got)
+			}
+		})
+	}
+}
+
+func TestMarshalYAML(t *testing.T) {
+	for _, testcase := range []struct {
+		name     string
+		value    interface{}
+		expected string
+	}{
+		{name: "null", value: nil, expected: "null\n"},
+		{name: "struct", value: struct {
+			Name  string `json:"name"`
+			Count int    `json:"count"`
+			On    bool   `json:"on"`
+		}{"show", 2, true}, expected: "name: show\ncount: 2\n\"on\": true\n"},
+		{name: "quoted strings", value: []string{"", " x", "true", "No", "1.5", "- a", "a: b", "#x", "x #y", "it's", "tab\t", "key:"}, expected: "- \"\"\n- \" x\"\n- \"true\"\n- \"No\"\n- \"1.5\"\n- \"- a\"\n- \"a: b\"\n- \"#x\"\n- \"x #y\"\n- it's\n- \"tab\\t\"\n- \"key:\"\n"},
+		{name: "empty collections", value: map[string]interface{}{"a": []int{}, "b": map[string]int{}}, expected: "a: []\nb: {}\n"},
+		{name: "nested lists", value: [][]string{{"a", "b"}, {}}, expected: "- - a\n  - b\n- []\n"},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			got, err := marshalYAML(testcase.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := testcase.expected; expected != string(got) {
+				t.Fatalf("expected %q, got %q", expected, got)
+			}
+			// Whatever is written can be read back.
+			back, err := yamlToJSON(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var expectedValue, gotValue interface{}
+			b, _ := json.Marshal(testcase.value)
+			if err := json.Unmarshal(b, &expectedValue); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(back, &gotValue); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(expectedValue, gotValue) {
+				t.Fatalf("expected %s to be read back, got %s", b, back)
+			}
+		})
+	}
+}